@@ -0,0 +1,329 @@
+package audiofile
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// aiff structure from https://www.mmsp.ece.mcgill.ca/Documents/AudioFormats/AIFF/Docs/AIFF-1.3.pdf
+// byte arrays for strings, ints for numbers -- everything in AIFF is
+// BigEndian, unlike Wavefile's LittleEndian numeric fields
+type Aiffheader struct {
+	FormID          [4]byte  // BigEndian, "FORM"
+	FormSize        uint32   // BigEndian
+	FormType        [4]byte  // BigEndian, "AIFF" or "AIFC"
+	CommID          [4]byte  // BigEndian, "COMM"
+	CommSize        uint32   // BigEndian
+	NumChannels     int16    // BigEndian
+	NumSampleFrames uint32   // BigEndian
+	SampleSize      int16    // BigEndian
+	SampleRate      [10]byte // BigEndian, 80bit IEEE 754 extended precision
+	CompressionType [4]byte  // BigEndian, AIFF-C only, "NONE" for raw PCM
+	CompressionName string   // AIFF-C only, pascal string in the file
+	SsndID          [4]byte  // BigEndian, "SSND"
+	SsndSize        uint32   // BigEndian
+	Offset          uint32   // BigEndian
+	BlockSize       uint32   // BigEndian
+}
+
+// Aifffile format -- *Aifffile implements AudioFile. It reads and writes
+// both plain AIFF and AIFF-C containers, though the only compression
+// type supported is "NONE" (uncompressed PCM stored in an AIFF-C shell)
+type Aifffile struct {
+	Header Aiffheader
+	Data   []byte
+}
+
+var aiffFormType = [4]byte{'A', 'I', 'F', 'F'}
+var aifcFormType = [4]byte{'A', 'I', 'F', 'C'}
+var noneCompressionType = [4]byte{'N', 'O', 'N', 'E'}
+
+func init() {
+	Register("aiff", func() AudioFile { return &Aifffile{} })
+}
+
+func (a *Aifffile) Load(r io.Reader) error {
+	if err := binary.Read(r, binary.BigEndian, &a.Header.FormID); err != nil {
+		return BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.FormSize); err != nil {
+		return BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.FormType); err != nil {
+		return BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.CommID); err != nil {
+		return BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.CommSize); err != nil {
+		return BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.NumChannels); err != nil {
+		return BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.NumSampleFrames); err != nil {
+		return BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.SampleSize); err != nil {
+		return BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.SampleRate); err != nil {
+		return BadFile
+	}
+	a.Header.CompressionType = [4]byte{}
+	a.Header.CompressionName = ""
+	if a.Header.FormType == aifcFormType {
+		if err := binary.Read(r, binary.BigEndian, &a.Header.CompressionType); err != nil {
+			return BadFile
+		}
+		name, err := readPascalString(r)
+		if err != nil {
+			return BadFile
+		}
+		a.Header.CompressionName = name
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.SsndID); err != nil {
+		return BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.SsndSize); err != nil {
+		return BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.Offset); err != nil {
+		return BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &a.Header.BlockSize); err != nil {
+		return BadFile
+	}
+	if a.Header.SsndSize < 8 {
+		return BadFile
+	}
+	a.Data = make([]byte, a.Header.SsndSize-8)
+	binary.Read(r, binary.BigEndian, &a.Data)
+	if len(a.Data)%2 != 0 {
+		var pad [1]byte
+		binary.Read(r, binary.BigEndian, &pad)
+	}
+
+	return validateAiff(a.Header)
+}
+
+func validateAiff(h Aiffheader) error {
+	if h.FormID != [4]byte{'F', 'O', 'R', 'M'} {
+		return BadFile
+	}
+	if h.FormType != aiffFormType && h.FormType != aifcFormType {
+		return BadFile
+	}
+	if h.CommID != [4]byte{'C', 'O', 'M', 'M'} {
+		return BadFile
+	}
+	if h.SsndID != [4]byte{'S', 'S', 'N', 'D'} {
+		return BadFile
+	}
+	if h.FormType == aifcFormType && h.CompressionType != noneCompressionType {
+		return BadFile
+	}
+	return nil
+}
+
+func (a *Aifffile) Save(w io.Writer) error {
+	a.Header.FormSize = a.computeFormSize()
+
+	if err := binary.Write(w, binary.BigEndian, a.Header.FormID); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.FormSize); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.FormType); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.CommID); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.CommSize); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.NumChannels); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.NumSampleFrames); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.SampleSize); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.SampleRate); err != nil {
+		return err
+	}
+	if a.Header.FormType == aifcFormType {
+		if err := binary.Write(w, binary.BigEndian, a.Header.CompressionType); err != nil {
+			return err
+		}
+		if err := writePascalString(w, a.Header.CompressionName); err != nil {
+			return err
+		}
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.SsndID); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.SsndSize); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.Offset); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Header.BlockSize); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, a.Data); err != nil {
+		return err
+	}
+	if len(a.Data)%2 != 0 {
+		if err := binary.Write(w, binary.BigEndian, byte(0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Aifffile) Init() {
+	a.Header.FormID = [4]byte{'F', 'O', 'R', 'M'}
+	a.Header.FormSize = 46
+	a.Header.FormType = aiffFormType
+	a.Header.CommID = [4]byte{'C', 'O', 'M', 'M'}
+	a.Header.CommSize = 18
+	a.Header.NumChannels = 1
+	a.Header.NumSampleFrames = 0
+	a.Header.SampleSize = 16
+	a.Header.SampleRate = hzToAiffSampleRate(44100)
+	a.Header.CompressionType = [4]byte{}
+	a.Header.CompressionName = ""
+	a.Header.SsndID = [4]byte{'S', 'S', 'N', 'D'}
+	a.Header.SsndSize = 8
+	a.Header.Offset = 0
+	a.Header.BlockSize = 0
+}
+
+func (a *Aifffile) GetBytes() []byte {
+	return a.Data
+}
+
+func (a *Aifffile) SetBytes(b []byte) {
+	a.Data = b
+	a.Header.SsndSize = uint32(len(b)) + 8
+}
+
+// computeFormSize recomputes the top-level FORM FormSize from the
+// current CommSize and SsndSize, so Save never writes a stale size
+// after Data is replaced out from under the header (by SetSamples,
+// ConvertTo, Gain, and the rest of the editing API, none of which
+// touch Header.FormSize themselves)
+func (a *Aifffile) computeFormSize() uint32 {
+	size := 4 + (8 + a.Header.CommSize) + (8 + a.Header.SsndSize)
+	if len(a.Data)%2 != 0 {
+		size++
+	}
+	return size
+}
+
+// ByteOrder reports that Aifffile's PCM sample data is big-endian, so that
+// GetPCM/SetPCM decode it correctly through the AudioFile interface
+func (a *Aifffile) ByteOrder() binary.ByteOrder {
+	return binary.BigEndian
+}
+
+// aiffSampleRateToHz converts the 80bit IEEE 754 extended-precision number
+// stored in an AIFF COMM chunk's SampleRate field into an integer Hz value
+func aiffSampleRateToHz(b [10]byte) uint32 {
+	expon := int(b[0]&0x7f)<<8 | int(b[1])
+	hiMant := uint64(b[2])<<24 | uint64(b[3])<<16 | uint64(b[4])<<8 | uint64(b[5])
+	loMant := uint64(b[6])<<24 | uint64(b[7])<<16 | uint64(b[8])<<8 | uint64(b[9])
+
+	if expon == 0 && hiMant == 0 && loMant == 0 {
+		return 0
+	}
+
+	expon -= 16383
+	f := math.Ldexp(float64(hiMant), expon-31)
+	f += math.Ldexp(float64(loMant), expon-63)
+
+	if b[0]&0x80 != 0 {
+		f = -f
+	}
+	return uint32(f + 0.5)
+}
+
+// hzToAiffSampleRate converts an integer Hz value into the 80bit IEEE 754
+// extended-precision representation used by an AIFF COMM chunk's
+// SampleRate field
+func hzToAiffSampleRate(hz uint32) [10]byte {
+	var b [10]byte
+	if hz == 0 {
+		return b
+	}
+
+	frac, expon := math.Frexp(float64(hz))
+	expon += 16382
+
+	frac = math.Ldexp(frac, 32)
+	hiMant := uint64(math.Floor(frac))
+	frac = math.Ldexp(frac-math.Floor(frac), 32)
+	loMant := uint64(math.Floor(frac))
+
+	b[0] = byte(expon >> 8)
+	b[1] = byte(expon)
+	b[2] = byte(hiMant >> 24)
+	b[3] = byte(hiMant >> 16)
+	b[4] = byte(hiMant >> 8)
+	b[5] = byte(hiMant)
+	b[6] = byte(loMant >> 24)
+	b[7] = byte(loMant >> 16)
+	b[8] = byte(loMant >> 8)
+	b[9] = byte(loMant)
+	return b
+}
+
+// readPascalString reads a Pascal-style string (a length byte followed by
+// that many characters, padded to an even number of bytes) as found after
+// the CompressionType in an AIFF-C COMM chunk
+func readPascalString(r io.Reader) (string, error) {
+	var l byte
+	if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+		return "", err
+	}
+	buf := make([]byte, l)
+	if l > 0 {
+		if err := binary.Read(r, binary.BigEndian, &buf); err != nil {
+			return "", err
+		}
+	}
+	if (int(l)+1)%2 != 0 {
+		var pad byte
+		if err := binary.Read(r, binary.BigEndian, &pad); err != nil {
+			return "", err
+		}
+	}
+	return string(buf), nil
+}
+
+// writePascalString writes s out as a Pascal-style string, padded to an
+// even number of bytes, mirroring readPascalString
+func writePascalString(w io.Writer, s string) error {
+	if len(s) > 255 {
+		s = s[:255]
+	}
+	if err := binary.Write(w, binary.BigEndian, byte(len(s))); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, []byte(s)); err != nil {
+		return err
+	}
+	if (len(s)+1)%2 != 0 {
+		if err := binary.Write(w, binary.BigEndian, byte(0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,45 +1,46 @@
 /*
-	Package audiofile is a pure Go generic audiofile library, it reads from a 
-	standard io.Reader and writes to an io.Writer
+Package audiofile is a pure Go generic audiofile library, it reads from a
+standard io.Reader and writes to an io.Writer
 
-	currently it supports wav files, with other lossless formats planned
+currently it supports wav, aiff/aiff-c and flac files
 */
 package audiofile
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	//	"fmt"
 	"io"
 )
 
-// The AudioReader interface is a file that can be read -- it can't 
+// The AudioReader interface is a file that can be read -- it can't
 // necessarily be written out in the case of a format like mp3 that
 // requires complex encoding
 type AudioReader interface {
-	// Load the audio file from an io.Reader It performs some basic 
+	// Load the audio file from an io.Reader It performs some basic
 	// sanity checks and returns an error if there is either an io error
 	// or if the file appears to be corrupted
 	Load(io.Reader) error
-	// GetBytes gets the raw bytes of audio data from the audio file. It performs 
+	// GetBytes gets the raw bytes of audio data from the audio file. It performs
 	// no conversions and does not return any of the metadata
 	GetBytes() []byte
 }
 
 // The AudioWriter interface is an audiofle that can be written out
 type AudioWriter interface {
-	// Writes a valid audio file out to the given io.Writer. It 
+	// Writes a valid audio file out to the given io.Writer. It
 	// should only return an error if there is an io error
 	Save(io.Writer) error
-	// Init sets up the audio file metadata for the 
+	// Init sets up the audio file metadata for the
 	// audiofile with the default values
 	Init()
-	// SetBytes sets the audio data to the given raw bytes. Like 
+	// SetBytes sets the audio data to the given raw bytes. Like
 	// GetBytes, it performs no conversions.
 	SetBytes([]byte)
 }
 
-// The AudioFile interface is a file that can be read or written. Most of the 
+// The AudioFile interface is a file that can be read or written. Most of the
 // supported file types should implement this interface
 type AudioFile interface {
 	AudioReader
@@ -56,7 +57,7 @@ const (
 )
 
 // wave structure from https://ccrma.stanford.edu/courses/422/projects/WaveFormat/
-// byte arrays for strings, uints for numbers 
+// byte arrays for strings, uints for numbers
 type Waveheader struct {
 	ChunkID       [4]byte // BigEndian
 	ChunkSize     uint32  // LittleEndian
@@ -73,59 +74,179 @@ type Waveheader struct {
 	Subchunk2Size uint32  // LittleEndian
 }
 
+// RiffChunk is a RIFF chunk that Wavefile doesn't interpret itself --
+// LIST/INFO, fact, bext, cue, smpl and the like. Load preserves these in
+// Wavefile.Chunks so Save can round-trip them back out unchanged.
+type RiffChunk struct {
+	ID   [4]byte
+	Data []byte
+}
+
+// waveFormatExtensible is the AudioFormat value (WAVE_FORMAT_EXTENSIBLE)
+// that signals the fmt chunk carries the extended fields in WaveFormat
+const waveFormatExtensible = 0xFFFE
+
+// WaveFormat is the parsed contents of a WAV fmt chunk. The common fields
+// are always populated; ValidBitsPerSample, ChannelMask and SubFormat are
+// only meaningful when AudioFormat is waveFormatExtensible
+type WaveFormat struct {
+	AudioFormat        uint16
+	NumChannels        uint16
+	SampleRate         uint32
+	ByteRate           uint32
+	BlockAlign         uint16
+	BitsPerSample      uint16
+	ValidBitsPerSample uint16
+	ChannelMask        uint32
+	SubFormat          [16]byte // GUID, BigEndian-in-file byte order preserved as-is
+}
+
 // Wavefile format -- *Wavefile implements AudioFile
 type Wavefile struct {
 	Header Waveheader
+	Format WaveFormat
 	Data   []byte
+	Chunks []RiffChunk
 }
 
 // BadFile indicates that the audiofile is corrupt
 var BadFile = errors.New("File is corrupt or not the proper format")
 
+func init() {
+	Register("wav", func() AudioFile { return &Wavefile{} })
+}
+
+// countingReader wraps an io.Reader, tallying the bytes that pass through
+// Read so ReadFrom can report them as its io.ReaderFrom byte count
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Load reads a Wavefile from r. It's a thin wrapper over ReadFrom for
+// callers that don't care about the number of bytes consumed
 func (w *Wavefile) Load(r io.Reader) error {
-	if err := binary.Read(r, binary.BigEndian, &w.Header.ChunkID); err != nil {
-		return BadFile
-	}
-	if err := binary.Read(r, binary.LittleEndian, &w.Header.ChunkSize); err != nil {
-		return BadFile
+	_, err := w.ReadFrom(r)
+	return err
+}
+
+// ReadFrom reads a Wavefile as a generic RIFF chunk walker: it reads the
+// RIFF/WAVE header, then iterates chunks until EOF, parsing fmt and data
+// itself and preserving any other chunk (LIST/INFO, fact, bext, cue,
+// smpl, ...) in Chunks so WriteTo can write them back out unchanged. It
+// satisfies io.ReaderFrom, returning the number of bytes consumed from r
+func (w *Wavefile) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+
+	if err := binary.Read(cr, binary.BigEndian, &w.Header.ChunkID); err != nil {
+		return cr.n, BadFile
 	}
-	if err := binary.Read(r, binary.BigEndian, &w.Header.Format); err != nil {
-		return BadFile
+	if err := binary.Read(cr, binary.LittleEndian, &w.Header.ChunkSize); err != nil {
+		return cr.n, BadFile
 	}
-	if err := binary.Read(r, binary.BigEndian, &w.Header.Subchunk1ID); err != nil {
-		return BadFile
+	if err := binary.Read(cr, binary.BigEndian, &w.Header.Format); err != nil {
+		return cr.n, BadFile
 	}
-	if err := binary.Read(r, binary.LittleEndian, &w.Header.Subchunk1Size); err != nil {
-		return BadFile
+
+	w.Chunks = nil
+	for {
+		var id [4]byte
+		var size uint32
+		if err := binary.Read(cr, binary.BigEndian, &id); err != nil {
+			break
+		}
+		if err := binary.Read(cr, binary.LittleEndian, &size); err != nil {
+			return cr.n, BadFile
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(cr, payload); err != nil {
+			return cr.n, BadFile
+		}
+		if size%2 != 0 {
+			var pad [1]byte
+			io.ReadFull(cr, pad[:])
+		}
+
+		switch id {
+		case [4]byte{'f', 'm', 't', ' '}:
+			if err := w.parseFmt(id, size, payload); err != nil {
+				return cr.n, err
+			}
+		case [4]byte{'d', 'a', 't', 'a'}:
+			w.Header.Subchunk2ID = id
+			w.Header.Subchunk2Size = size
+			w.Data = payload
+		default:
+			w.Chunks = append(w.Chunks, RiffChunk{ID: id, Data: payload})
+		}
 	}
-	if err := binary.Read(r, binary.LittleEndian, &w.Header.AudioFormat); err != nil {
+
+	return cr.n, validate(w.Header)
+}
+
+// parseFmt decodes a fmt chunk of size 16 (canonical PCM), 18 (PCM with a
+// trailing cbSize) or 40 (WAVE_FORMAT_EXTENSIBLE) into both w.Format and
+// the legacy fields on w.Header
+func (w *Wavefile) parseFmt(id [4]byte, size uint32, payload []byte) error {
+	if size < 16 {
 		return BadFile
 	}
-	if err := binary.Read(r, binary.LittleEndian, &w.Header.NumChannels); err != nil {
+	r := bytes.NewReader(payload)
+
+	var f WaveFormat
+	if err := binary.Read(r, binary.LittleEndian, &f.AudioFormat); err != nil {
 		return BadFile
 	}
-	if err := binary.Read(r, binary.LittleEndian, &w.Header.SampleRate); err != nil {
+	if err := binary.Read(r, binary.LittleEndian, &f.NumChannels); err != nil {
 		return BadFile
 	}
-	if err := binary.Read(r, binary.LittleEndian, &w.Header.ByteRate); err != nil {
+	if err := binary.Read(r, binary.LittleEndian, &f.SampleRate); err != nil {
 		return BadFile
 	}
-	if err := binary.Read(r, binary.LittleEndian, &w.Header.BlockAlign); err != nil {
+	if err := binary.Read(r, binary.LittleEndian, &f.ByteRate); err != nil {
 		return BadFile
 	}
-	if err := binary.Read(r, binary.LittleEndian, &w.Header.BitsPerSample); err != nil {
+	if err := binary.Read(r, binary.LittleEndian, &f.BlockAlign); err != nil {
 		return BadFile
 	}
-	if err := binary.Read(r, binary.BigEndian, &w.Header.Subchunk2ID); err != nil {
+	if err := binary.Read(r, binary.LittleEndian, &f.BitsPerSample); err != nil {
 		return BadFile
 	}
-	if err := binary.Read(r, binary.LittleEndian, &w.Header.Subchunk2Size); err != nil {
-		return BadFile
+
+	if size >= 18 {
+		var cbSize uint16
+		if err := binary.Read(r, binary.LittleEndian, &cbSize); err != nil {
+			return BadFile
+		}
+		if f.AudioFormat == waveFormatExtensible && cbSize >= 22 {
+			if err := binary.Read(r, binary.LittleEndian, &f.ValidBitsPerSample); err != nil {
+				return BadFile
+			}
+			if err := binary.Read(r, binary.LittleEndian, &f.ChannelMask); err != nil {
+				return BadFile
+			}
+			if err := binary.Read(r, binary.LittleEndian, &f.SubFormat); err != nil {
+				return BadFile
+			}
+		}
 	}
-	w.Data = make([]byte, w.Header.Subchunk2Size)
-	binary.Read(r, binary.LittleEndian, &w.Data)
 
-	return validate(w.Header)
+	w.Format = f
+	w.Header.Subchunk1ID = id
+	w.Header.Subchunk1Size = size
+	w.Header.AudioFormat = f.AudioFormat
+	w.Header.NumChannels = f.NumChannels
+	w.Header.SampleRate = f.SampleRate
+	w.Header.ByteRate = f.ByteRate
+	w.Header.BlockAlign = f.BlockAlign
+	w.Header.BitsPerSample = f.BitsPerSample
+	return nil
 }
 func validate(h Waveheader) error {
 	if h.ChunkID != [4]byte{'R', 'I', 'F', 'F'} {
@@ -143,16 +264,83 @@ func validate(h Waveheader) error {
 	return nil
 }
 
+// countingWriter wraps an io.Writer, tallying the bytes that pass through
+// Write so WriteTo can report them as its io.WriterTo byte count
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Save writes a Wavefile to writer. It's a thin wrapper over WriteTo for
+// callers that don't care about the number of bytes written
 func (w *Wavefile) Save(writer io.Writer) error {
-	if err := binary.Write(writer, binary.BigEndian, w.Header.ChunkID); err != nil {
-		return err
-	}
-	if err := binary.Write(writer, binary.LittleEndian, w.Header.ChunkSize); err != nil {
-		return err
-	}
-	if err := binary.Write(writer, binary.BigEndian, w.Header.Format); err != nil {
-		return err
-	}
+	_, err := w.WriteTo(writer)
+	return err
+}
+
+// WriteTo writes the RIFF/WAVE header, the fmt and data chunks, and any
+// chunks preserved by ReadFrom back out to writer. It satisfies
+// io.WriterTo, returning the number of bytes written
+func (w *Wavefile) WriteTo(writer io.Writer) (int64, error) {
+	cw := &countingWriter{w: writer}
+
+	w.Header.ChunkSize = w.computeChunkSize()
+
+	if err := binary.Write(cw, binary.BigEndian, w.Header.ChunkID); err != nil {
+		return cw.n, err
+	}
+	if err := binary.Write(cw, binary.LittleEndian, w.Header.ChunkSize); err != nil {
+		return cw.n, err
+	}
+	if err := binary.Write(cw, binary.BigEndian, w.Header.Format); err != nil {
+		return cw.n, err
+	}
+	if err := w.writeFmtChunk(cw); err != nil {
+		return cw.n, err
+	}
+	if err := binary.Write(cw, binary.BigEndian, w.Header.Subchunk2ID); err != nil {
+		return cw.n, err
+	}
+	if err := binary.Write(cw, binary.LittleEndian, w.Header.Subchunk2Size); err != nil {
+		return cw.n, err
+	}
+	if err := binary.Write(cw, binary.LittleEndian, w.Data); err != nil {
+		return cw.n, err
+	}
+	if len(w.Data)%2 != 0 {
+		if err := binary.Write(cw, binary.LittleEndian, byte(0)); err != nil {
+			return cw.n, err
+		}
+	}
+	for _, c := range w.Chunks {
+		if err := binary.Write(cw, binary.BigEndian, c.ID); err != nil {
+			return cw.n, err
+		}
+		if err := binary.Write(cw, binary.LittleEndian, uint32(len(c.Data))); err != nil {
+			return cw.n, err
+		}
+		if err := binary.Write(cw, binary.LittleEndian, c.Data); err != nil {
+			return cw.n, err
+		}
+		if len(c.Data)%2 != 0 {
+			if err := binary.Write(cw, binary.LittleEndian, byte(0)); err != nil {
+				return cw.n, err
+			}
+		}
+	}
+	return cw.n, nil
+}
+
+// writeFmtChunk writes the fmt chunk, sized according to
+// Header.Subchunk1Size (16 canonical, 18 PCM-with-cbSize, or 40
+// WAVE_FORMAT_EXTENSIBLE), pulling the extended fields from w.Format
+func (w *Wavefile) writeFmtChunk(writer io.Writer) error {
 	if err := binary.Write(writer, binary.BigEndian, w.Header.Subchunk1ID); err != nil {
 		return err
 	}
@@ -177,13 +365,23 @@ func (w *Wavefile) Save(writer io.Writer) error {
 	if err := binary.Write(writer, binary.LittleEndian, w.Header.BitsPerSample); err != nil {
 		return err
 	}
-	if err := binary.Write(writer, binary.BigEndian, w.Header.Subchunk2ID); err != nil {
+	if w.Header.Subchunk1Size < 18 {
+		return nil
+	}
+	cbSize := uint16(w.Header.Subchunk1Size - 18)
+	if err := binary.Write(writer, binary.LittleEndian, cbSize); err != nil {
+		return err
+	}
+	if w.Header.AudioFormat != waveFormatExtensible || cbSize < 22 {
+		return nil
+	}
+	if err := binary.Write(writer, binary.LittleEndian, w.Format.ValidBitsPerSample); err != nil {
 		return err
 	}
-	if err := binary.Write(writer, binary.LittleEndian, w.Header.Subchunk2Size); err != nil {
+	if err := binary.Write(writer, binary.LittleEndian, w.Format.ChannelMask); err != nil {
 		return err
 	}
-	if err := binary.Write(writer, binary.LittleEndian, w.Data); err != nil {
+	if err := binary.Write(writer, binary.LittleEndian, w.Format.SubFormat); err != nil {
 		return err
 	}
 	return nil
@@ -212,11 +410,45 @@ func (w *Wavefile) SetBytes(b []byte) {
 	w.Header.Subchunk2Size = uint32(len(b))
 }
 
+// computeChunkSize recomputes the top-level RIFF ChunkSize from the
+// actual size of the fmt chunk, w.Data, and any preserved Chunks, so
+// WriteTo never writes a stale size after Data is replaced out from
+// under the header (by SetSamples, ConvertTo, Gain, and the rest of
+// the editing API, none of which touch Header.ChunkSize themselves)
+func (w *Wavefile) computeChunkSize() uint32 {
+	size := uint32(4) + 8 + uint32(w.Header.Subchunk1Size) // Format + fmt chunk
+	size += 8 + uint32(len(w.Data))
+	if len(w.Data)%2 != 0 {
+		size++
+	}
+	for _, c := range w.Chunks {
+		size += 8 + uint32(len(c.Data))
+		if len(c.Data)%2 != 0 {
+			size++
+		}
+	}
+	return size
+}
+
+// ByteOrderer is implemented by audio files whose raw PCM data is not
+// stored little-endian (the default GetPCM/SetPCM assume). Wavefile
+// doesn't implement it; Aifffile does, since AIFF sample data is big-endian.
+type ByteOrderer interface {
+	ByteOrder() binary.ByteOrder
+}
+
 // GetPCM is a utility function -- it calls GetBytes and interperets the data
-// as 16bit signed LPCM data
+// as 16bit signed LPCM data, using the AudioReader's byte order if it
+// implements ByteOrderer, or little-endian otherwise
 func GetPCM(areader AudioReader) []int16 {
 	bytes := areader.GetBytes()
 	out := make([]int16, len(bytes)/2)
+	if bo, ok := areader.(ByteOrderer); ok && bo.ByteOrder() == binary.BigEndian {
+		for i := range out {
+			out[i] = BytesToSigned16(bytes[i*2+1], bytes[i*2])
+		}
+		return out
+	}
 	for i := range out {
 		out[i] = BytesToSigned16(bytes[i*2], bytes[i*2+1])
 	}
@@ -224,9 +456,19 @@ func GetPCM(areader AudioReader) []int16 {
 }
 
 // SetPCM is a utility function -- it saves the given 16bit signed LPCM data
-// in the given AudioWriter
+// in the given AudioWriter, using the AudioWriter's byte order if it
+// implements ByteOrderer, or little-endian otherwise
 func SetPCM(awriter AudioWriter, pcm []int16) {
 	bytes := make([]byte, len(pcm)*2)
+	if bo, ok := awriter.(ByteOrderer); ok && bo.ByteOrder() == binary.BigEndian {
+		for i := range pcm {
+			low, high := Signed16ToBytes(pcm[i])
+			bytes[i*2] = high
+			bytes[i*2+1] = low
+		}
+		awriter.SetBytes(bytes)
+		return
+	}
 	for i := range pcm {
 		low, high := Signed16ToBytes(pcm[i])
 		bytes[i*2] = low
@@ -235,35 +477,15 @@ func SetPCM(awriter AudioWriter, pcm []int16) {
 	awriter.SetBytes(bytes)
 }
 
-// BytesToSigned16 is a bit by bit copy of 2 bytes into a signed 16bit value
-func BytesToSigned16(low, high byte) (out int16) {
-	if high == 128 && low == 0 {
-		return MIN_16_BIT
-	}
-	highi16 := int16(high & 127)
-	highshifted := highi16 << 8
-	out = highshifted + int16(low)
-
-	if neg := high & 128; neg != 0 {
-		out *= -1
-	}
-
-	return out
+// BytesToSigned16 recombines a little-endian two's complement byte pair
+// into a signed 16bit value
+func BytesToSigned16(low, high byte) int16 {
+	return int16(uint16(low) | uint16(high)<<8)
 }
 
-// Signed16ToBytes is a bit by bit copy of a signed 16bit int into 2 bytes
+// Signed16ToBytes splits a signed 16bit value into its little-endian two's
+// complement byte pair
 func Signed16ToBytes(in int16) (low, high byte) {
-	if in == -1 {
-		return 0, 128
-	}
-	if in < 0 {
-		in = (^in) + 1
-		low = byte(in)
-		high = byte(in >> 8)
-		high |= 128
-	} else {
-		low = byte(in)
-		high = byte(in >> 8)
-	}
-	return
+	u := uint16(in)
+	return byte(u), byte(u >> 8)
 }
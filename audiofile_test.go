@@ -2,6 +2,7 @@ package audiofile
 
 import (
 	"bytes"
+	"encoding/binary"
 	"testing"
 )
 
@@ -14,8 +15,8 @@ var validWaveBuf []byte = []byte{
 	16, 0, 0, 0, //20 Subchunk1Size
 	1, 0, //22 AudioFormat
 	1, 0, //24 NumChannels
-	0x44, 0xAC, 0, 0, //28 SampleRate 44100 
-	0x88, 0x58, 0x01, 0, //32 ByteRate 
+	0x44, 0xAC, 0, 0, //28 SampleRate 44100
+	0x88, 0x58, 0x01, 0, //32 ByteRate
 	2, 0, // 34 block Align
 	16, 0, // 36 BitsPerSample
 	'd', 'a', 't', 'a', // 40 Subchunk2ID
@@ -179,7 +180,402 @@ func Test_SaveShouldGiveTheSameBytesAsInputPreviously(t *testing.T) {
 	}
 }
 
+var validWaveBufWithList []byte = []byte{
+	'R', 'I', 'F', 'F',
+	50, 0, 0, 0,
+	'W', 'A', 'V', 'E',
+	'f', 'm', 't', ' ',
+	16, 0, 0, 0,
+	1, 0,
+	1, 0,
+	0x44, 0xAC, 0, 0,
+	0x88, 0x58, 0x01, 0,
+	2, 0,
+	16, 0,
+	'd', 'a', 't', 'a',
+	2, 0, 0, 0,
+	1, 2,
+	'L', 'I', 'S', 'T',
+	4, 0, 0, 0,
+	'I', 'N', 'F', 'O'}
+
+func Test_WaveLoadPreservesUnknownChunks(t *testing.T) {
+	af := &Wavefile{}
+	if err := af.Load(bytes.NewBuffer(validWaveBufWithList)); err != nil {
+		t.Errorf("expected no error loading wav with a LIST chunk, got %v", err)
+		return
+	}
+	if len(af.Chunks) != 1 {
+		t.Errorf("expected 1 preserved chunk, got %v", len(af.Chunks))
+		return
+	}
+	if af.Chunks[0].ID != [4]byte{'L', 'I', 'S', 'T'} {
+		t.Errorf("expected preserved chunk to be LIST, got %v", af.Chunks[0].ID)
+	}
+	if bytes.Compare(af.Chunks[0].Data, []byte{'I', 'N', 'F', 'O'}) != 0 {
+		t.Errorf("expected preserved chunk data to be INFO, got %v", af.Chunks[0].Data)
+	}
+	if bytes.Compare(af.GetBytes(), []byte{1, 2}) != 0 {
+		t.Errorf("expected data chunk to still be parsed, got %v", af.GetBytes())
+	}
+}
+
+func Test_WaveSaveRoundTripsUnknownChunks(t *testing.T) {
+	af := &Wavefile{}
+	if err := af.Load(bytes.NewBuffer(validWaveBufWithList)); err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(validWaveBufWithList)))
+	if err := af.Save(out); err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+	if bytes.Compare(out.Bytes(), validWaveBufWithList) != 0 {
+		t.Errorf("expected round trip to reproduce input bytes.\n\nexpected %v\ngot %v", validWaveBufWithList, out.Bytes())
+	}
+}
+
+var extensibleFmtPayload = []byte{
+	0xFE, 0xFF, // AudioFormat WAVE_FORMAT_EXTENSIBLE
+	2, 0, // NumChannels
+	0x44, 0xAC, 0, 0, // SampleRate 44100
+	0x10, 0xB1, 0x02, 0x00, // ByteRate
+	4, 0, // BlockAlign
+	16, 0, // BitsPerSample
+	22, 0, // cbSize
+	16, 0, // ValidBitsPerSample
+	3, 0, 0, 0, // ChannelMask (front left | front right)
+	// SubFormat GUID, KSDATAFORMAT_SUBTYPE_PCM
+	0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00,
+	0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+
+func validExtensibleWaveBuf() []byte {
+	header := []byte{
+		'R', 'I', 'F', 'F',
+		62, 0, 0, 0,
+		'W', 'A', 'V', 'E',
+		'f', 'm', 't', ' ',
+		40, 0, 0, 0}
+	trailer := []byte{
+		'd', 'a', 't', 'a',
+		2, 0, 0, 0,
+		1, 2}
+	return bytes.Join([][]byte{header, extensibleFmtPayload, trailer}, nil)
+}
+
+func Test_WaveLoadParsesExtensibleFmt(t *testing.T) {
+	af := &Wavefile{}
+	if err := af.Load(bytes.NewBuffer(validExtensibleWaveBuf())); err != nil {
+		t.Errorf("expected no error loading a WAVE_FORMAT_EXTENSIBLE wav, got %v", err)
+		return
+	}
+	if af.Format.AudioFormat != waveFormatExtensible {
+		t.Errorf("expected AudioFormat to be WAVE_FORMAT_EXTENSIBLE, got %v", af.Format.AudioFormat)
+	}
+	if af.Format.ValidBitsPerSample != 16 {
+		t.Errorf("expected ValidBitsPerSample 16, got %v", af.Format.ValidBitsPerSample)
+	}
+	if af.Format.ChannelMask != 3 {
+		t.Errorf("expected ChannelMask 3, got %v", af.Format.ChannelMask)
+	}
+}
+
+func Test_WaveSaveRoundTripsExtensibleFmt(t *testing.T) {
+	buf := validExtensibleWaveBuf()
+	af := &Wavefile{}
+	if err := af.Load(bytes.NewBuffer(buf)); err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(buf)))
+	if err := af.Save(out); err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+	if bytes.Compare(out.Bytes(), buf) != 0 {
+		t.Errorf("expected round trip to reproduce input bytes.\n\nexpected %v\ngot %v", buf, out.Bytes())
+	}
+}
+
 /******* AIFF FILES *******/
+var validAiffBuf []byte = []byte{
+	'F', 'O', 'R', 'M', //4 FormID
+	0, 0, 0, 46, //8 FormSize
+	'A', 'I', 'F', 'F', //12 FormType
+	'C', 'O', 'M', 'M', //16 CommID
+	0, 0, 0, 18, //20 CommSize
+	0, 1, //22 NumChannels
+	0, 0, 0, 0, //26 NumSampleFrames
+	0, 16, //28 SampleSize
+	0x40, 0x0E, 0xAC, 0x44, 0, 0, 0, 0, 0, 0, //38 SampleRate 44100
+	'S', 'S', 'N', 'D', //42 SsndID
+	0, 0, 0, 8, //46 SsndSize
+	0, 0, 0, 0, //50 Offset
+	0, 0, 0, 0} //54 BlockSize
+
+func Test_AifffileShouldBeAbleToBeUsedAsAnAudiofile(t *testing.T) {
+	// This test is mostly a static assertion
+	var af AudioFile = &Aifffile{}
+	switch af.(type) {
+	default:
+		t.Errorf("Aifffile should be able to be converted back and forth with AudioFile inteface")
+	case *Aifffile:
+	}
+}
+
+func Test_AiffEmptyReaderShouldReturnAnError(t *testing.T) {
+	empty := bytes.NewBuffer([]byte{})
+	af := &Aifffile{}
+	err := af.Load(empty)
+	if err == nil {
+		t.Errorf("expected to get an error when loading an empty file, got nil instead")
+	}
+}
+
+func Test_ValidAiffHeaderShouldNotReturnAnError(t *testing.T) {
+	af := &Aifffile{}
+	err := af.Load(bytes.NewBuffer(validAiffBuf))
+	if err != nil {
+		t.Errorf("expected no errors with valid aiff header, got %v", err)
+	}
+}
+func badAiffFormId() *bytes.Buffer {
+	newbuf := make([]byte, len(validAiffBuf))
+	copy(newbuf, validAiffBuf)
+	newbuf[0] = 0
+	return bytes.NewBuffer(newbuf)
+}
+func badAiffFormType() *bytes.Buffer {
+	newbuf := make([]byte, len(validAiffBuf))
+	copy(newbuf, validAiffBuf)
+	newbuf[8] = 0
+	return bytes.NewBuffer(newbuf)
+}
+func badAiffCommId() *bytes.Buffer {
+	newbuf := make([]byte, len(validAiffBuf))
+	copy(newbuf, validAiffBuf)
+	newbuf[12] = 0
+	return bytes.NewBuffer(newbuf)
+}
+func badAiffSsndId() *bytes.Buffer {
+	newbuf := make([]byte, len(validAiffBuf))
+	copy(newbuf, validAiffBuf)
+	newbuf[38] = 0
+	return bytes.NewBuffer(newbuf)
+}
+func Test_ShouldCheckForAiffFileCorruption(t *testing.T) {
+	buffuncs := []func() *bytes.Buffer{
+		badAiffFormId,
+		badAiffFormType,
+		badAiffCommId,
+		badAiffSsndId}
+	msgs := []string{
+		"FORM header ID",
+		"FORM type",
+		"COMM header ID",
+		"SSND header ID"}
+
+	for i := range buffuncs {
+		buf := buffuncs[i]()
+		af := Aifffile{}
+		err := af.Load(buf)
+		if err == nil {
+			t.Errorf("Expected an error on corrupted %v, got no error", msgs[i])
+		}
+	}
+}
+func Test_InitShouldProduceAValidAiff(t *testing.T) {
+	af := &Aifffile{}
+	af.Init()
+	if err := validateAiff(af.Header); err != nil {
+		t.Errorf("Expected Init to produce a valid aifffile, got error %v", err)
+	}
+}
+func Test_AiffGetBytesShouldReturnTheBytesOfDataFromTheFile(t *testing.T) {
+	newData := []byte{0, 0, 127, 255, 0, 0, 128, 0, 0, 0}
+
+	newBuf := bytes.Join([][]byte{validAiffBuf, newData}, nil)
+	newBuf[45] = byte(len(newData) + 8)
+
+	af := &Aifffile{}
+	err := af.Load(bytes.NewBuffer(newBuf))
+	if err != nil {
+		t.Errorf("expected no errors with valid aiff file with data, got %v on file %v", err, newBuf)
+	}
+	data := af.GetBytes()
+	if bytes.Compare(newData, data) != 0 {
+		t.Errorf("expected data returned from aifffile.GetBytes() to be the same as the input data.\n\nexpected %v, got %v",
+			newData, data)
+	}
+}
+func Test_AiffSetBytesShouldUpdateTheInternalBuffer(t *testing.T) {
+	newData := []byte{0, 0, 127, 255, 0, 0, 128, 0, 0, 0}
+	af := &Aifffile{}
+	af.Init()
+	af.SetBytes(newData)
+	if bytes.Compare(af.Data, newData) != 0 {
+		t.Errorf("expected to get %v, got %v in SetBytes()", newData, af.Data)
+	}
+}
+func Test_AiffSetBytesShouldUpdateTheSizeCount(t *testing.T) {
+	newData := []byte{0, 0, 127, 255, 0, 0, 128, 0, 0, 0}
+	af := &Aifffile{}
+	af.Init()
+	af.SetBytes(newData)
+	if af.Header.SsndSize != uint32(len(newData))+8 {
+		t.Errorf("expected SsndSize to be %v, found %v", len(newData)+8, af.Header.SsndSize)
+	}
+}
+
+func Test_AiffSaveShouldSucceed(t *testing.T) {
+	af := &Aifffile{}
+	af.Load(bytes.NewBuffer(validAiffBuf))
+	outbuf := make([]byte, 1024)
+	err := af.Save(bytes.NewBuffer(outbuf))
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func Test_AiffSaveShouldGiveTheSameBytesAsInputPreviously(t *testing.T) {
+	af := &Aifffile{}
+	if err := af.Load(bytes.NewBuffer(validAiffBuf)); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	outbuf := bytes.NewBuffer(make([]byte, 0, 1024))
+	err := af.Save(outbuf)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	b := outbuf.Bytes()
+	if bytes.Compare(b, validAiffBuf) != 0 {
+		t.Errorf("expected %v (len %v), got %v (len %v)", validAiffBuf, len(validAiffBuf), b, len(b))
+	}
+}
+
+func Test_AiffOddLengthSsndRoundTripsWithItsPadByte(t *testing.T) {
+	// a 1 byte SSND payload is odd-length, so per the AIFF spec it must
+	// be followed by a pad byte -- Load must skip it and Save must
+	// write it back, the same even-padding Wavefile already does for
+	// RIFF chunks
+	buf := make([]byte, len(validAiffBuf)+2)
+	copy(buf, validAiffBuf)
+	binary.BigEndian.PutUint32(buf[4:8], 48)  // FormSize += 2 for the data byte + pad
+	binary.BigEndian.PutUint32(buf[42:46], 9) // SsndSize = 8 + 1 data byte
+	buf[54] = 0x7F                            // the 1 byte of sample data
+	buf[55] = 0                               // required pad byte
+
+	af := &Aifffile{}
+	if err := af.Load(bytes.NewBuffer(buf)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(af.Data) != 1 || af.Data[0] != 0x7F {
+		t.Fatalf("expected a single 0x7F data byte, got %v", af.Data)
+	}
+
+	outbuf := bytes.NewBuffer(make([]byte, 0, len(buf)))
+	if err := af.Save(outbuf); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if bytes.Compare(outbuf.Bytes(), buf) != 0 {
+		t.Errorf("expected %v (len %v), got %v (len %v)", buf, len(buf), outbuf.Bytes(), outbuf.Len())
+	}
+}
+
+func Test_AiffSampleRateRoundTrips(t *testing.T) {
+	rates := []uint32{44100, 48000, 22050, 96000, 8000}
+	for _, rate := range rates {
+		extended := hzToAiffSampleRate(rate)
+		if result := aiffSampleRateToHz(extended); result != rate {
+			t.Errorf("expected sample rate %v to round trip, got %v", rate, result)
+		}
+	}
+}
+
+func Test_AiffGetPCMUsesBigEndianByteOrder(t *testing.T) {
+	af := &Aifffile{}
+	af.Init()
+	af.SetBytes([]byte{0xFF, 0xFF})
+	pcm := GetPCM(af)
+	if !comparei16(pcm, []int16{-1}) {
+		t.Errorf("expected big-endian bytes {0xFF, 0xFF} to decode to [-1], got %v", pcm)
+	}
+}
+
+func Test_AiffSetPCMUsesBigEndianByteOrder(t *testing.T) {
+	af := &Aifffile{}
+	af.Init()
+	SetPCM(af, []int16{1})
+	if bytes.Compare(af.GetBytes(), []byte{0x00, 0x01}) != 0 {
+		t.Errorf("expected 1 to encode to big-endian bytes {0x00, 0x01}, got %v", af.GetBytes())
+	}
+}
+
+/******* AIFF-C FILES *******/
+var validAifcBuf []byte = []byte{
+	'F', 'O', 'R', 'M', //4 FormID
+	0, 0, 0, 66, //8 FormSize
+	'A', 'I', 'F', 'C', //12 FormType
+	'C', 'O', 'M', 'M', //16 CommID
+	0, 0, 0, 38, //20 CommSize
+	0, 1, //22 NumChannels
+	0, 0, 0, 0, //26 NumSampleFrames
+	0, 16, //28 SampleSize
+	0x40, 0x0E, 0xAC, 0x44, 0, 0, 0, 0, 0, 0, //38 SampleRate 44100
+	'N', 'O', 'N', 'E', //42 CompressionType
+	14,                                                                   //43 CompressionName length
+	'n', 'o', 't', ' ', 'c', 'o', 'm', 'p', 'r', 'e', 's', 's', 'e', 'd', //57 CompressionName
+	0,                  //58 CompressionName padding
+	'S', 'S', 'N', 'D', //62 SsndID
+	0, 0, 0, 8, //66 SsndSize
+	0, 0, 0, 0, //70 Offset
+	0, 0, 0, 0} //74 BlockSize
+
+func Test_ValidAifcHeaderShouldNotReturnAnError(t *testing.T) {
+	af := &Aifffile{}
+	err := af.Load(bytes.NewBuffer(validAifcBuf))
+	if err != nil {
+		t.Errorf("expected no errors with valid aifc header, got %v", err)
+	}
+	if af.Header.CompressionType != noneCompressionType {
+		t.Errorf("expected CompressionType NONE, got %v", af.Header.CompressionType)
+	}
+	if af.Header.CompressionName != "not compressed" {
+		t.Errorf("expected CompressionName %q, got %q", "not compressed", af.Header.CompressionName)
+	}
+}
+
+func Test_AifcSaveShouldGiveTheSameBytesAsInputPreviously(t *testing.T) {
+	af := &Aifffile{}
+	if err := af.Load(bytes.NewBuffer(validAifcBuf)); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	outbuf := bytes.NewBuffer(make([]byte, 0, 1024))
+	if err := af.Save(outbuf); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	b := outbuf.Bytes()
+	if bytes.Compare(b, validAifcBuf) != 0 {
+		t.Errorf("expected %v (len %v), got %v (len %v)", validAifcBuf, len(validAifcBuf), b, len(b))
+	}
+}
+
+func Test_ShouldCheckForAifcUnsupportedCompressionType(t *testing.T) {
+	newbuf := make([]byte, len(validAifcBuf))
+	copy(newbuf, validAifcBuf)
+	// replace "NONE" with "ALAW", a compressed AIFF-C type this package
+	// doesn't decode
+	newbuf[38] = 'A'
+	newbuf[39] = 'L'
+	newbuf[40] = 'A'
+	newbuf[41] = 'W'
+
+	af := &Aifffile{}
+	if err := af.Load(bytes.NewBuffer(newbuf)); err == nil {
+		t.Errorf("expected an error loading an AIFF-C file with an unsupported compression type, got no error")
+	}
+}
 
 /******* UTIL FUNCTIONS *******/
 func Test_BytesToSigned16(t *testing.T) {
@@ -188,7 +584,7 @@ func Test_BytesToSigned16(t *testing.T) {
 		{255, 127},
 		{0, 128},
 		{1, 0},
-		{1, 128}}
+		{255, 255}}
 	expected := []int16{
 		0,
 		MAX_16_BIT,
@@ -207,7 +603,7 @@ func Test_Signed16ToBytes(t *testing.T) {
 		{255, 127},
 		{0, 128},
 		{1, 0},
-		{0, 128}}
+		{255, 255}}
 	inputs := []int16{
 		0,
 		MAX_16_BIT,
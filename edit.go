@@ -0,0 +1,177 @@
+package audiofile
+
+import (
+	"math"
+	"time"
+)
+
+// Append, Slice, Mix and Gain are free functions taking an AudioFile
+// rather than methods on the AudioFile interface, following the
+// convention GetPCM/SetPCM/GetSamples/SetSamples/ConvertTo already use
+// elsewhere in this package: GetSamples/SetSamples are generic over the
+// sample type, and Go methods can't take type parameters of their own,
+// so operating on AudioFile through free functions is the only option
+// that lets the sample-format-aware helpers and this editing API share
+// one calling convention
+
+// Append decodes other's samples, converting them to af's own sample
+// rate and channel count if they differ, and appends them after af's
+// existing PCM data. It returns BadFile if other is nil
+func Append(af AudioFile, other AudioFile) error {
+	if other == nil {
+		return BadFile
+	}
+
+	format, bigEndian := formatOf(af)
+	afSamples := decodeSamples(format, bigEndian, af.GetBytes())
+	otherSamples := decodeForFormat(other, format)
+
+	channels := len(afSamples)
+	if channels == 0 {
+		channels = len(otherSamples)
+	}
+	merged := make([][]float64, channels)
+	for ch := range merged {
+		merged[ch] = append(channelOrEmpty(afSamples, ch), channelOrEmpty(otherSamples, ch)...)
+	}
+
+	af.SetBytes(encodeSamples(format, bigEndian, merged))
+	return nil
+}
+
+// Slice returns a new AudioFile of the same concrete type as af,
+// containing af's samples between start and end. start and end are
+// clamped to af's own length, and swapped if end comes before start
+func Slice(af AudioFile, start, end time.Duration) AudioFile {
+	format, bigEndian := formatOf(af)
+	samples := decodeSamples(format, bigEndian, af.GetBytes())
+
+	frames := 0
+	if len(samples) > 0 {
+		frames = len(samples[0])
+	}
+	startFrame := clampFrame(framesFor(format.SampleRate, start), frames)
+	endFrame := clampFrame(framesFor(format.SampleRate, end), frames)
+	if endFrame < startFrame {
+		startFrame, endFrame = endFrame, startFrame
+	}
+
+	sliced := make([][]float64, len(samples))
+	for ch, data := range samples {
+		sliced[ch] = append([]float64{}, data[startFrame:endFrame]...)
+	}
+
+	out := cloneContainer(af)
+	out.Init()
+	applyFormat(out, format)
+	out.SetBytes(encodeSamples(format, bigEndian, sliced))
+	return out
+}
+
+// Mix decodes other's samples, converting them to af's own sample rate
+// and channel count if they differ, scales them by gainDB (converted to
+// linear amplitude), and adds them into af's samples starting at frame
+// 0. The result is clamped to -1..1 to avoid wraparound on clipping. It
+// returns BadFile if other is nil
+func Mix(af AudioFile, other AudioFile, gainDB float64) error {
+	if other == nil {
+		return BadFile
+	}
+
+	format, bigEndian := formatOf(af)
+	samples := decodeSamples(format, bigEndian, af.GetBytes())
+	otherSamples := decodeForFormat(other, format)
+
+	gain := math.Pow(10, gainDB/20)
+	for ch := range samples {
+		if ch >= len(otherSamples) {
+			break
+		}
+		for i := range samples[ch] {
+			if i >= len(otherSamples[ch]) {
+				break
+			}
+			samples[ch][i] = clamp(samples[ch][i] + otherSamples[ch][i]*gain)
+		}
+	}
+
+	af.SetBytes(encodeSamples(format, bigEndian, samples))
+	return nil
+}
+
+// Gain scales af's own samples by db decibels in place, clamping the
+// result to -1..1 to avoid wraparound on clipping
+func Gain(af AudioFile, db float64) {
+	format, bigEndian := formatOf(af)
+	samples := decodeSamples(format, bigEndian, af.GetBytes())
+
+	gain := math.Pow(10, db/20)
+	for ch := range samples {
+		for i := range samples[ch] {
+			samples[ch][i] = clamp(samples[ch][i] * gain)
+		}
+	}
+
+	af.SetBytes(encodeSamples(format, bigEndian, samples))
+}
+
+// decodeForFormat decodes af's samples and, if its own format differs
+// from target, remaps its channels and resamples it to match
+func decodeForFormat(af AudioFile, target WaveFormat) [][]float64 {
+	format, bigEndian := formatOf(af)
+	samples := decodeSamples(format, bigEndian, af.GetBytes())
+
+	if format.NumChannels != target.NumChannels {
+		samples = remapChannels(samples, int(target.NumChannels))
+	}
+	if format.SampleRate != target.SampleRate {
+		samples = resample(samples, format.SampleRate, target.SampleRate)
+	}
+	return samples
+}
+
+// channelOrEmpty returns samples[ch], or an empty slice if ch is out of
+// range
+func channelOrEmpty(samples [][]float64, ch int) []float64 {
+	if ch < len(samples) {
+		return append([]float64{}, samples[ch]...)
+	}
+	return nil
+}
+
+// clampFrame clamps frame to the range 0..max
+func clampFrame(frame, max int) int {
+	if frame < 0 {
+		return 0
+	}
+	if frame > max {
+		return max
+	}
+	return frame
+}
+
+// clamp restricts v to the range -1..1
+func clamp(v float64) float64 {
+	switch {
+	case v > 1:
+		return 1
+	case v < -1:
+		return -1
+	default:
+		return v
+	}
+}
+
+// cloneContainer returns a new, zero-valued AudioFile of the same
+// concrete type as af, defaulting to *Wavefile for types this package
+// doesn't recognize
+func cloneContainer(af AudioFile) AudioFile {
+	switch af.(type) {
+	case *Aifffile:
+		return &Aifffile{}
+	case *Flacfile:
+		return &Flacfile{}
+	default:
+		return &Wavefile{}
+	}
+}
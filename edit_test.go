@@ -0,0 +1,122 @@
+package audiofile
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_AppendConcatenatesSamples(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	SetSamples(af, [][]int16{{1, 2}})
+
+	other := &Wavefile{}
+	other.Init()
+	SetSamples(other, [][]int16{{3, 4}})
+
+	if err := Append(af, other); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	samples := GetSamples[int16](af)
+	expected := []int16{1, 2, 3, 4}
+	if !comparei16(samples[0], expected) {
+		t.Errorf("expected %v, got %v", expected, samples[0])
+	}
+}
+
+func Test_AppendReturnsBadFileForNilOther(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	if err := Append(af, nil); err != BadFile {
+		t.Errorf("expected BadFile, got %v", err)
+	}
+}
+
+func Test_SliceReturnsTheRequestedRange(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	af.Header.SampleRate = 1
+	SetSamples(af, [][]int16{{1, 2, 3, 4, 5}})
+
+	sliced := Slice(af, time.Second, 3*time.Second)
+
+	samples := GetSamples[int16](sliced)
+	expected := []int16{2, 3}
+	if !comparei16(samples[0], expected) {
+		t.Errorf("expected %v, got %v", expected, samples[0])
+	}
+}
+
+func Test_GainScalesSamples(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	SetSamples(af, [][]int16{{16384}})
+
+	Gain(af, -6)
+
+	samples := GetSamples[int16](af)
+	if samples[0][0] >= 16384 || samples[0][0] <= 0 {
+		t.Errorf("expected -6dB to attenuate a positive sample, got %v", samples[0][0])
+	}
+}
+
+func Test_GainClampsFullScaleInsteadOfWrapping(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	SetSamples(af, [][]int16{{MAX_16_BIT}})
+
+	Gain(af, 6) // positive gain pushes the already full-scale sample past 1.0
+
+	samples := GetSamples[int16](af)
+	if samples[0][0] != MAX_16_BIT {
+		t.Errorf("expected gain to clamp at %d, got %d (wrapped to most-negative)", MAX_16_BIT, samples[0][0])
+	}
+}
+
+func Test_MixClampsFullScaleInsteadOfWrapping(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	SetSamples(af, [][]int16{{MAX_16_BIT}})
+
+	other := &Wavefile{}
+	other.Init()
+	SetSamples(other, [][]int16{{MAX_16_BIT}})
+
+	if err := Mix(af, other, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	samples := GetSamples[int16](af)
+	if samples[0][0] != MAX_16_BIT {
+		t.Errorf("expected mix to clamp at %d, got %d (wrapped to most-negative)", MAX_16_BIT, samples[0][0])
+	}
+}
+
+func Test_MixAddsOtherIntoAf(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	SetSamples(af, [][]int16{{0, 0}})
+
+	other := &Wavefile{}
+	other.Init()
+	SetSamples(other, [][]int16{{100, -100}})
+
+	if err := Mix(af, other, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	samples := GetSamples[int16](af)
+	expected := []int16{100, -100}
+	if !comparei16(samples[0], expected) {
+		t.Errorf("expected %v, got %v", expected, samples[0])
+	}
+}
+
+func Test_MixReturnsBadFileForNilOther(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	if err := Mix(af, nil, 0); err != BadFile {
+		t.Errorf("expected BadFile, got %v", err)
+	}
+}
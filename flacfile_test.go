@@ -0,0 +1,370 @@
+package audiofile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func Test_FlacfileShouldBeAbleToBeUsedAsAnAudiofile(t *testing.T) {
+	// This test is mostly a static assertion
+	var af AudioFile = &Flacfile{}
+	switch af.(type) {
+	default:
+		t.Errorf("Flacfile should be able to be converted back and forth with AudioFile inteface")
+	case *Flacfile:
+	}
+}
+
+func Test_FlacEmptyReaderShouldReturnAnError(t *testing.T) {
+	empty := bytes.NewBuffer([]byte{})
+	af := &Flacfile{}
+	err := af.Load(empty)
+	if err == nil {
+		t.Errorf("expected to get an error when loading an empty file, got nil instead")
+	}
+}
+
+func Test_FlacSaveLoadRoundTripsPCM(t *testing.T) {
+	pcm := []byte{1, 0, 2, 0, 0x9C, 0xFF, 0x00, 0x80} // 1, 2, -100, -32768 (this package's 16bit codec)
+
+	af := &Flacfile{}
+	af.Init()
+	af.SetBytes(pcm)
+
+	var buf bytes.Buffer
+	if err := af.Save(&buf); err != nil {
+		t.Errorf("expected no error saving, got %v", err)
+		return
+	}
+
+	loaded := &Flacfile{}
+	if err := loaded.Load(bytes.NewBuffer(buf.Bytes())); err != nil {
+		t.Errorf("expected no error loading, got %v", err)
+		return
+	}
+
+	if bytes.Compare(loaded.GetBytes(), pcm) != 0 {
+		t.Errorf("expected %v, got %v", pcm, loaded.GetBytes())
+	}
+	if loaded.StreamInfo.NumChannels != 1 || loaded.StreamInfo.BitsPerSample != 16 || loaded.StreamInfo.SampleRate != 44100 {
+		t.Errorf("expected StreamInfo to round trip, got %+v", loaded.StreamInfo)
+	}
+}
+
+func Test_FlacGetPCMUsesLittleEndianByteOrder(t *testing.T) {
+	af := &Flacfile{}
+	af.Init()
+	af.SetBytes([]byte{1, 0, 2, 0})
+
+	pcm := GetPCM(af)
+	if len(pcm) != 2 || pcm[0] != 1 || pcm[1] != 2 {
+		t.Errorf("expected [1 2], got %v", pcm)
+	}
+}
+
+/******* hand-built bitstream fixtures *******
+ *
+ * Flacfile.Save only ever emits VERBATIM subframes on independent
+ * channels, so the tests above never drive decodeFixedSubframe,
+ * decodeLPCSubframe, stereo decorrelation, or readResidual's
+ * partitioned Rice decoding -- the paths a real-world encoder (and
+ * the spec) actually exercise. The helpers below hand-assemble frames
+ * bit-by-bit with the package's own bitWriter, the same primitive
+ * writeFrame uses, so each test below is driving Load through exactly
+ * the bits a real encoder would have written.
+ */
+
+// flacStreamInfoPayload builds a minimal 34 byte STREAMINFO payload --
+// MinBlockSize/MaxBlockSize/TotalSamples are left at 0 since nothing in
+// decodeFlacFrames consults them (block size comes from the frame header)
+func flacStreamInfoPayload(channels, bps uint8, sampleRate uint32) []byte {
+	payload := make([]byte, 34)
+	packed := (uint64(sampleRate) << 44) | (uint64(channels-1) << 41) | (uint64(bps-1) << 36)
+	binary.BigEndian.PutUint64(payload[10:18], packed)
+	return payload
+}
+
+// flacTestFile assembles a complete FLAC stream out of a STREAMINFO
+// payload and pre-encoded frame bytes
+func flacTestFile(payload, frame []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(flacMagic[:])
+	buf.Write([]byte{0x80, byte(len(payload) >> 16), byte(len(payload) >> 8), byte(len(payload))})
+	buf.Write(payload)
+	buf.Write(frame)
+	return buf.Bytes()
+}
+
+// writeFlacFrameHeader writes a fixed-blocksize frame header for
+// blockSize/channelCode, using STREAMINFO for sample rate and bits per
+// sample (codes 0) and frame number 0 encoded as a single UTF-8 byte
+func writeFlacFrameHeader(bw *bitWriter, blockSize int, channelCode uint64) {
+	bw.writeBits(0x3FFE, 14)
+	bw.writeBit(0) // reserved
+	bw.writeBit(0) // fixed-blocksize stream
+	code, extraBits, extraVal := encodeBlockSizeCode(blockSize)
+	bw.writeBits(uint64(code), 4)
+	bw.writeBits(0, 4) // sample rate: from STREAMINFO
+	bw.writeBits(channelCode, 4)
+	bw.writeBits(0, 3) // sample size: from STREAMINFO
+	bw.writeBit(0)     // reserved
+	bw.writeBits(0, 8) // frame number 0, single byte utf8
+	if extraBits > 0 {
+		bw.writeBits(uint64(extraVal), uint(extraBits))
+	}
+	bw.writeBits(0, 8) // header CRC-8, unchecked
+}
+
+// writeFlacFrameFooter byte-aligns and writes the (unchecked) footer CRC
+func writeFlacFrameFooter(bw *bitWriter) {
+	bw.flush()
+	bw.writeBits(0, 16)
+	bw.flush()
+}
+
+// writeFlacVerbatimSubframe writes a VERBATIM subframe, one raw sample per
+// value in samples, with no wasted bits
+func writeFlacVerbatimSubframe(bw *bitWriter, samples []int64, bps uint) {
+	bw.writeBit(0)
+	bw.writeBits(1, 6) // SUBFRAME_VERBATIM
+	bw.writeBit(0)     // no wasted bits
+	for _, v := range samples {
+		bw.writeSigned(v, bps)
+	}
+}
+
+// writeFlacRiceResidual writes a partitioned Rice-coded residual (method 0,
+// 4bit Rice parameters) for blockSize total samples, predictorOrder of
+// which were stored as warm-up samples, one Rice parameter per partition
+func writeFlacRiceResidual(bw *bitWriter, residual []int64, predictorOrder, partitionOrder int, params []uint) {
+	bw.writeBits(0, 2) // method 0: 4bit Rice parameters
+	bw.writeBits(uint64(partitionOrder), 4)
+	blockSize := len(residual) + predictorOrder
+	partitions := 1 << uint(partitionOrder)
+	idx := 0
+	for p := 0; p < partitions; p++ {
+		count := blockSize / partitions
+		if p == 0 {
+			count -= predictorOrder
+		}
+		param := params[p]
+		bw.writeBits(uint64(param), 4)
+		for i := 0; i < count; i++ {
+			v := residual[idx]
+			idx++
+			var u uint64
+			if v >= 0 {
+				u = uint64(v) * 2
+			} else {
+				u = uint64(-v)*2 - 1
+			}
+			q := u >> param
+			for j := uint64(0); j < q; j++ {
+				bw.writeBit(0)
+			}
+			bw.writeBit(1)
+			bw.writeBits(u&(1<<param-1), uint(param))
+		}
+	}
+}
+
+// writeFlacFixedSubframe writes a FIXED-predictor subframe: order warm-up
+// samples followed by a single-partition Rice-coded residual
+func writeFlacFixedSubframe(bw *bitWriter, warmup, residual []int64, bps uint, order int, param uint) {
+	bw.writeBit(0)
+	bw.writeBits(uint64(8+order), 6)
+	bw.writeBit(0) // no wasted bits
+	for _, v := range warmup {
+		bw.writeSigned(v, bps)
+	}
+	writeFlacRiceResidual(bw, residual, order, 0, []uint{param})
+}
+
+func decodeFlacTestFile(t *testing.T, channels, bps uint8, raw []byte) *Flacfile {
+	t.Helper()
+	af := &Flacfile{}
+	if err := af.Load(bytes.NewBuffer(raw)); err != nil {
+		t.Fatalf("expected no error loading, got %v", err)
+	}
+	return af
+}
+
+func Test_FlacDecodesFixedSubframeWithResidual(t *testing.T) {
+	// samples [100, 150, 130, 200], order-1 fixed prediction: predicted[i]
+	// = samples[i-1], so residual = [50, -20, 70]
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	writeFlacFrameHeader(bw, 4, 0) // mono
+	writeFlacFixedSubframe(bw, []int64{100}, []int64{50, -20, 70}, 16, 1, 8)
+	writeFlacFrameFooter(bw)
+
+	raw := flacTestFile(flacStreamInfoPayload(1, 16, 44100), buf.Bytes())
+	af := decodeFlacTestFile(t, 1, 16, raw)
+
+	expected := []int16{100, 150, 130, 200}
+	var want bytes.Buffer
+	for _, v := range expected {
+		lo, hi := Signed16ToBytes(v)
+		want.WriteByte(lo)
+		want.WriteByte(hi)
+	}
+	if bytes.Compare(af.GetBytes(), want.Bytes()) != 0 {
+		t.Errorf("expected %v, got %v", want.Bytes(), af.GetBytes())
+	}
+}
+
+func Test_FlacDecodesLPCSubframe(t *testing.T) {
+	// samples [10, 20, 25, 50, 70], order-2 LPC with coeffs [1, 1], shift 0:
+	// predicted[i] = samples[i-1] + samples[i-2], residual = [-5, 5, -5]
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	writeFlacFrameHeader(bw, 5, 0) // mono
+
+	bw.writeBit(0)
+	bw.writeBits(uint64(32+1), 6) // LPC order 2 (32 + order - 1)
+	bw.writeBit(0)                // no wasted bits
+	bw.writeSigned(10, 16)
+	bw.writeSigned(20, 16)
+	bw.writeBits(uint64(2-1), 4) // precision 2, stored as precision-1
+	bw.writeSigned(0, 5)         // shift
+	bw.writeSigned(1, 2)
+	bw.writeSigned(1, 2)
+	writeFlacRiceResidual(bw, []int64{-5, 5, -5}, 2, 0, []uint{5})
+	writeFlacFrameFooter(bw)
+
+	raw := flacTestFile(flacStreamInfoPayload(1, 16, 44100), buf.Bytes())
+	af := decodeFlacTestFile(t, 1, 16, raw)
+
+	expected := []int16{10, 20, 25, 50, 70}
+	var want bytes.Buffer
+	for _, v := range expected {
+		lo, hi := Signed16ToBytes(v)
+		want.WriteByte(lo)
+		want.WriteByte(hi)
+	}
+	if bytes.Compare(af.GetBytes(), want.Bytes()) != 0 {
+		t.Errorf("expected %v, got %v", want.Bytes(), af.GetBytes())
+	}
+}
+
+func Test_FlacDecodesMidSideStereo(t *testing.T) {
+	// left/right pairs (101,80) and (110,90) encoded as mid=(l+r)>>1,
+	// side=l-r -- mid/side is channel code 10, and the side channel
+	// carries one extra bit of precision
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	writeFlacFrameHeader(bw, 2, 10)
+	writeFlacVerbatimSubframe(bw, []int64{90, 100}, 16) // mid
+	writeFlacVerbatimSubframe(bw, []int64{21, 20}, 17)  // side
+	writeFlacFrameFooter(bw)
+
+	raw := flacTestFile(flacStreamInfoPayload(2, 16, 44100), buf.Bytes())
+	af := decodeFlacTestFile(t, 2, 16, raw)
+
+	expected := []int16{101, 80, 110, 90} // interleaved L,R
+	var want bytes.Buffer
+	for _, v := range expected {
+		lo, hi := Signed16ToBytes(v)
+		want.WriteByte(lo)
+		want.WriteByte(hi)
+	}
+	if bytes.Compare(af.GetBytes(), want.Bytes()) != 0 {
+		t.Errorf("expected %v, got %v", want.Bytes(), af.GetBytes())
+	}
+}
+
+func Test_FlacDecodesLeftSideStereo(t *testing.T) {
+	// left/right pair (100,70): left/side is channel code 8, storing
+	// left verbatim and side = left-right
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	writeFlacFrameHeader(bw, 1, 8)
+	writeFlacVerbatimSubframe(bw, []int64{100}, 16) // left
+	writeFlacVerbatimSubframe(bw, []int64{30}, 17)  // side = left-right
+	writeFlacFrameFooter(bw)
+
+	raw := flacTestFile(flacStreamInfoPayload(2, 16, 44100), buf.Bytes())
+	af := decodeFlacTestFile(t, 2, 16, raw)
+
+	expected := []int16{100, 70}
+	var want bytes.Buffer
+	for _, v := range expected {
+		lo, hi := Signed16ToBytes(v)
+		want.WriteByte(lo)
+		want.WriteByte(hi)
+	}
+	if bytes.Compare(af.GetBytes(), want.Bytes()) != 0 {
+		t.Errorf("expected %v, got %v", want.Bytes(), af.GetBytes())
+	}
+}
+
+func Test_FlacDecodesPartitionedResidualAcrossMultiplePartitions(t *testing.T) {
+	// order-0 FIXED prediction makes the residual equal the samples
+	// directly, split into 2 partitions with different Rice parameters
+	// (3 and 7) -- exercises readResidual's partition boundary and its
+	// per-partition parameter handling
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	writeFlacFrameHeader(bw, 4, 0)
+
+	bw.writeBit(0)
+	bw.writeBits(8, 6) // FIXED order 0
+	bw.writeBit(0)     // no wasted bits
+	writeFlacRiceResidual(bw, []int64{5, -3, 100, -90}, 0, 1, []uint{3, 7})
+	writeFlacFrameFooter(bw)
+
+	raw := flacTestFile(flacStreamInfoPayload(1, 16, 44100), buf.Bytes())
+	af := decodeFlacTestFile(t, 1, 16, raw)
+
+	expected := []int16{5, -3, 100, -90}
+	var want bytes.Buffer
+	for _, v := range expected {
+		lo, hi := Signed16ToBytes(v)
+		want.WriteByte(lo)
+		want.WriteByte(hi)
+	}
+	if bytes.Compare(af.GetBytes(), want.Bytes()) != 0 {
+		t.Errorf("expected %v, got %v", want.Bytes(), af.GetBytes())
+	}
+}
+
+func Test_FlacDecodesMultiByteUTF8FrameNumber(t *testing.T) {
+	// a 2-byte UTF-8 coded frame number (lead 0xCF, continuation 0xA8,
+	// decoding to 1000) -- unused by the decoder beyond being read and
+	// discarded, but it must consume the right number of bits or
+	// everything after it in the frame desyncs
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	bw.writeBits(0x3FFE, 14)
+	bw.writeBit(0)
+	bw.writeBit(0)
+	code, extraBits, extraVal := encodeBlockSizeCode(1)
+	bw.writeBits(uint64(code), 4)
+	bw.writeBits(0, 4)
+	bw.writeBits(0, 4) // mono
+	bw.writeBits(0, 3)
+	bw.writeBit(0)
+	bw.writeBits(0xCF, 8) // multi-byte utf8 lead byte
+	bw.writeBits(0xA8, 8) // continuation byte -- together decode to 1000
+	if extraBits > 0 {
+		bw.writeBits(uint64(extraVal), uint(extraBits))
+	}
+	bw.writeBits(0, 8) // header CRC
+	writeFlacVerbatimSubframe(bw, []int64{42}, 16)
+	writeFlacFrameFooter(bw)
+
+	raw := flacTestFile(flacStreamInfoPayload(1, 16, 44100), buf.Bytes())
+	af := decodeFlacTestFile(t, 1, 16, raw)
+
+	expected := []int16{42}
+	var want bytes.Buffer
+	for _, v := range expected {
+		lo, hi := Signed16ToBytes(v)
+		want.WriteByte(lo)
+		want.WriteByte(hi)
+	}
+	if bytes.Compare(af.GetBytes(), want.Bytes()) != 0 {
+		t.Errorf("expected %v, got %v", want.Bytes(), af.GetBytes())
+	}
+}
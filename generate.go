@@ -0,0 +1,57 @@
+package audiofile
+
+import (
+	"math"
+	"time"
+)
+
+// NewSilence returns a Wavefile containing duration worth of silence at
+// format's sample rate, channel count and bit depth
+func NewSilence(format WaveFormat, duration time.Duration) AudioFile {
+	w := newWavefile(format)
+	frames := framesFor(format.SampleRate, duration)
+
+	samples := make([][]float64, w.Header.NumChannels)
+	for ch := range samples {
+		samples[ch] = make([]float64, frames)
+	}
+
+	w.SetBytes(encodeSamples(normalizeFormat(format), false, samples))
+	return w
+}
+
+// NewSine returns a Wavefile containing duration worth of a freq Hz sine
+// wave at format's sample rate, channel count and bit depth, identical on
+// every channel
+func NewSine(format WaveFormat, freq float64, duration time.Duration) AudioFile {
+	w := newWavefile(format)
+	frames := framesFor(format.SampleRate, duration)
+
+	samples := make([][]float64, w.Header.NumChannels)
+	for ch := range samples {
+		data := make([]float64, frames)
+		for i := range data {
+			t := float64(i) / float64(w.Header.SampleRate)
+			data[i] = math.Sin(2 * math.Pi * freq * t)
+		}
+		samples[ch] = data
+	}
+
+	w.SetBytes(encodeSamples(normalizeFormat(format), false, samples))
+	return w
+}
+
+// newWavefile returns an Init'd Wavefile with its header fields set to
+// format, defaulting any zero fields the same way ConvertTo does
+func newWavefile(format WaveFormat) *Wavefile {
+	w := &Wavefile{}
+	w.Init()
+	applyFormat(w, normalizeFormat(format))
+	return w
+}
+
+// framesFor returns the number of sample frames duration covers at
+// sampleRate Hz
+func framesFor(sampleRate uint32, duration time.Duration) int {
+	return int(duration.Seconds() * float64(sampleRate))
+}
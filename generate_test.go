@@ -0,0 +1,68 @@
+package audiofile
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func Test_NewSilenceProducesSilentSamples(t *testing.T) {
+	af := NewSilence(WaveFormat{NumChannels: 2, SampleRate: 1000, BitsPerSample: 16}, time.Second)
+
+	samples := GetSamples[int16](af)
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 channels, got %d", len(samples))
+	}
+	if len(samples[0]) != 1000 {
+		t.Fatalf("expected 1000 frames for 1 second at 1000Hz, got %d", len(samples[0]))
+	}
+	for ch, data := range samples {
+		for i, v := range data {
+			if v != 0 {
+				t.Errorf("expected silence, got %d at channel %d frame %d", v, ch, i)
+			}
+		}
+	}
+}
+
+func Test_NewSineProducesASineWave(t *testing.T) {
+	af := NewSine(WaveFormat{NumChannels: 1, SampleRate: 8000, BitsPerSample: 16}, 1000, time.Second)
+
+	samples := GetSamples[float64](af)
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 channel, got %d", len(samples))
+	}
+	if len(samples[0]) != 8000 {
+		t.Fatalf("expected 8000 frames for 1 second at 8000Hz, got %d", len(samples[0]))
+	}
+	if samples[0][0] != 0 {
+		t.Errorf("expected a sine wave to start at 0, got %v", samples[0][0])
+	}
+}
+
+func Test_NewSinePeaksDoNotWrapToMostNegative(t *testing.T) {
+	// a 1000Hz tone at 8000Hz sample rate lands its first quarter-cycle
+	// peak exactly on sample 2 (sin(pi/2) == 1.0), which used to overflow
+	// into the most-negative 16bit value on encode instead of saturating
+	af := NewSine(WaveFormat{NumChannels: 1, SampleRate: 8000, BitsPerSample: 16}, 1000, time.Second)
+
+	samples := GetSamples[int16](af)
+	if samples[0][2] != MAX_16_BIT {
+		t.Errorf("expected the sine wave's peak to saturate at %d, got %d", MAX_16_BIT, samples[0][2])
+	}
+}
+
+func Test_NewSilenceSavesAChunkSizeMatchingTheActualFileLength(t *testing.T) {
+	af := NewSilence(WaveFormat{NumChannels: 1, SampleRate: 8000, BitsPerSample: 16}, time.Second)
+
+	var buf bytes.Buffer
+	if err := af.Save(&buf); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	wantChunkSize := uint32(buf.Len() - 8)
+	w := af.(*Wavefile)
+	if w.Header.ChunkSize != wantChunkSize {
+		t.Errorf("expected ChunkSize %d (file is %d bytes), got %d", wantChunkSize, buf.Len(), w.Header.ChunkSize)
+	}
+}
@@ -0,0 +1,65 @@
+package audiofile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// ErrUnsupportedFormat indicates Open recognized the container from its
+// magic bytes but no codec/container backend is registered to read it
+var ErrUnsupportedFormat = errors.New("audiofile: recognized but unsupported format")
+
+// Factory constructs a new, empty AudioFile of a particular codec/container
+type Factory func() AudioFile
+
+var registry = map[string]Factory{}
+
+// Register adds a codec/container backend under name, so Open can
+// construct one once it has sniffed a matching magic number. Built-in
+// backends (Wavefile, Aifffile, Flacfile) register themselves from their
+// own init() functions
+func Register(name string, factory Factory) {
+	registry[name] = factory
+}
+
+// Open sniffs the first 4 bytes of r to identify its container (RIFF,
+// FORM, fLaC, OggS or ID3), then Loads the rest of the stream into the
+// registered AudioFile for that container. It returns ErrUnsupportedFormat
+// if the container is recognized but nothing is registered for it, and
+// BadFile if the magic bytes don't match any known container at all
+func Open(r io.Reader) (AudioFile, error) {
+	br := bufio.NewReader(r)
+	peeked, err := br.Peek(4)
+	if err != nil {
+		return nil, BadFile
+	}
+
+	var name string
+	switch {
+	case bytes.HasPrefix(peeked, []byte("RIFF")):
+		name = "wav"
+	case bytes.HasPrefix(peeked, []byte("FORM")):
+		name = "aiff"
+	case bytes.HasPrefix(peeked, []byte("fLaC")):
+		name = "flac"
+	case bytes.HasPrefix(peeked, []byte("OggS")):
+		name = "ogg"
+	case bytes.HasPrefix(peeked, []byte("ID3")):
+		name = "mp3"
+	default:
+		return nil, BadFile
+	}
+
+	factory, ok := registry[name]
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+
+	af := factory()
+	if err := af.Load(br); err != nil {
+		return nil, err
+	}
+	return af, nil
+}
@@ -0,0 +1,62 @@
+package audiofile
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_OpenSniffsWaveFiles(t *testing.T) {
+	af, err := Open(bytes.NewBuffer(validWaveBuf))
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+	if _, ok := af.(*Wavefile); !ok {
+		t.Errorf("expected a *Wavefile, got %T", af)
+	}
+}
+
+func Test_OpenSniffsAiffFiles(t *testing.T) {
+	af, err := Open(bytes.NewBuffer(validAiffBuf))
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+	if _, ok := af.(*Aifffile); !ok {
+		t.Errorf("expected a *Aifffile, got %T", af)
+	}
+}
+
+func Test_OpenSniffsFlacFiles(t *testing.T) {
+	var buf bytes.Buffer
+	ff := &Flacfile{}
+	ff.Init()
+	ff.SetBytes([]byte{1, 0, 2, 0})
+	if err := ff.Save(&buf); err != nil {
+		t.Errorf("expected no error saving, got %v", err)
+		return
+	}
+
+	af, err := Open(bytes.NewBuffer(buf.Bytes()))
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+	if _, ok := af.(*Flacfile); !ok {
+		t.Errorf("expected a *Flacfile, got %T", af)
+	}
+}
+
+func Test_OpenReturnsUnsupportedFormatForRecognizedButUnregisteredContainers(t *testing.T) {
+	ogg := bytes.NewBuffer([]byte{'O', 'g', 'g', 'S', 0, 0, 0, 0})
+	if _, err := Open(ogg); err != ErrUnsupportedFormat {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func Test_OpenReturnsBadFileForUnrecognizedMagic(t *testing.T) {
+	junk := bytes.NewBuffer([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	if _, err := Open(junk); err != BadFile {
+		t.Errorf("expected BadFile, got %v", err)
+	}
+}
@@ -0,0 +1,442 @@
+package audiofile
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// waveFormatIEEEFloat is the AudioFormat value (WAVE_FORMAT_IEEE_FLOAT)
+// that signals PCM data is stored as 32 or 64bit floats rather than
+// integers
+const waveFormatIEEEFloat = 3
+
+// Sample is the set of PCM sample types GetSamples/SetSamples can decode
+// into or encode from: int16/int32 for integer PCM, float32/float64 for
+// WAVE_FORMAT_IEEE_FLOAT data
+type Sample interface {
+	int16 | int32 | float32 | float64
+}
+
+// formatOf reports the WaveFormat describing af's PCM data, and whether
+// that data is big-endian. Wavefile and Flacfile already carry enough of
+// a WaveFormat to use directly; Aifffile's AIFF-native header is adapted
+// into one. Anything else is assumed to be 16bit mono PCM, matching
+// GetPCM/SetPCM's long-standing default
+func formatOf(af AudioFile) (WaveFormat, bool) {
+	bigEndian := false
+	if bo, ok := af.(ByteOrderer); ok {
+		bigEndian = bo.ByteOrder() == binary.BigEndian
+	}
+
+	switch t := af.(type) {
+	case *Wavefile:
+		return WaveFormat{
+			AudioFormat:   t.Header.AudioFormat,
+			NumChannels:   t.Header.NumChannels,
+			SampleRate:    t.Header.SampleRate,
+			ByteRate:      t.Header.ByteRate,
+			BlockAlign:    t.Header.BlockAlign,
+			BitsPerSample: t.Header.BitsPerSample,
+		}, bigEndian
+	case *Aifffile:
+		return WaveFormat{
+			AudioFormat:   1,
+			NumChannels:   uint16(t.Header.NumChannels),
+			SampleRate:    aiffSampleRateToHz(t.Header.SampleRate),
+			BitsPerSample: uint16(t.Header.SampleSize),
+		}, bigEndian
+	case *Flacfile:
+		return WaveFormat{
+			AudioFormat:   1,
+			NumChannels:   uint16(t.StreamInfo.NumChannels),
+			SampleRate:    t.StreamInfo.SampleRate,
+			BitsPerSample: uint16(t.StreamInfo.BitsPerSample),
+		}, bigEndian
+	default:
+		return WaveFormat{AudioFormat: 1, NumChannels: 1, BitsPerSample: 16}, bigEndian
+	}
+}
+
+// GetSamples decodes af's PCM data into one slice per channel, normalizing
+// integer PCM into T's native range and passing WAVE_FORMAT_IEEE_FLOAT
+// data through as-is
+func GetSamples[T Sample](af AudioFile) [][]T {
+	format, bigEndian := formatOf(af)
+	normalized := decodeSamples(format, bigEndian, af.GetBytes())
+
+	out := make([][]T, len(normalized))
+	for ch, data := range normalized {
+		out[ch] = make([]T, len(data))
+		for i, v := range data {
+			out[ch][i] = fromNormalized[T](v)
+		}
+	}
+	return out
+}
+
+// SetSamples encodes one slice per channel of T back into af's PCM data,
+// at af's current format
+func SetSamples[T Sample](af AudioFile, samples [][]T) {
+	format, bigEndian := formatOf(af)
+
+	normalized := make([][]float64, len(samples))
+	for ch, data := range samples {
+		normalized[ch] = make([]float64, len(data))
+		for i, v := range data {
+			normalized[ch][i] = toNormalized(v)
+		}
+	}
+	af.SetBytes(encodeSamples(format, bigEndian, normalized))
+}
+
+// ConvertTo re-encodes af's PCM data to match format -- resampling,
+// remapping channels and changing bit depth as needed -- and updates af's
+// own format fields to match, so a mono 8bit file can be re-saved as
+// stereo 16bit. It returns BadFile if af isn't a container this package
+// knows how to update the format fields of
+func ConvertTo(af AudioFile, format WaveFormat) error {
+	format = normalizeFormat(format)
+
+	srcFormat, bigEndian := formatOf(af)
+	samples := decodeSamples(srcFormat, bigEndian, af.GetBytes())
+
+	samples = remapChannels(samples, int(format.NumChannels))
+	if format.SampleRate != 0 && srcFormat.SampleRate != 0 {
+		samples = resample(samples, srcFormat.SampleRate, format.SampleRate)
+	}
+
+	if err := applyFormat(af, format); err != nil {
+		return err
+	}
+
+	af.SetBytes(encodeSamples(format, bigEndian, samples))
+	return nil
+}
+
+// normalizeFormat fills in sensible defaults (PCM, mono, 16bit) for any
+// zero fields in format, so callers can specify just the fields they
+// care about
+func normalizeFormat(format WaveFormat) WaveFormat {
+	if format.AudioFormat == 0 {
+		format.AudioFormat = 1
+	}
+	if format.NumChannels == 0 {
+		format.NumChannels = 1
+	}
+	if format.BitsPerSample == 0 {
+		format.BitsPerSample = 16
+	}
+	return format
+}
+
+// applyFormat updates af's own format fields to match format, so later
+// reads of af via formatOf see the format its PCM data was just encoded
+// at. It returns BadFile if af isn't a container this package knows how
+// to update the format fields of
+func applyFormat(af AudioFile, format WaveFormat) error {
+	format = normalizeFormat(format)
+
+	switch t := af.(type) {
+	case *Wavefile:
+		blockAlign := format.NumChannels * (format.BitsPerSample / 8)
+		t.Header.AudioFormat = format.AudioFormat
+		t.Header.NumChannels = format.NumChannels
+		t.Header.SampleRate = format.SampleRate
+		t.Header.ByteRate = format.SampleRate * uint32(blockAlign)
+		t.Header.BlockAlign = blockAlign
+		t.Header.BitsPerSample = format.BitsPerSample
+	case *Aifffile:
+		t.Header.NumChannels = int16(format.NumChannels)
+		t.Header.SampleRate = hzToAiffSampleRate(format.SampleRate)
+		t.Header.SampleSize = int16(format.BitsPerSample)
+	case *Flacfile:
+		t.StreamInfo.NumChannels = uint8(format.NumChannels)
+		t.StreamInfo.SampleRate = format.SampleRate
+		t.StreamInfo.BitsPerSample = uint8(format.BitsPerSample)
+	default:
+		return BadFile
+	}
+	return nil
+}
+
+// remapChannels converts samples to target channels, handling the common
+// mono<->stereo cases directly; converting to more than 2 target channels
+// repeats the last source channel, down-mixing to mono averages every
+// source channel, and down-mixing to 2 or more channels drops the extras
+// rather than mixing them
+func remapChannels(samples [][]float64, target int) [][]float64 {
+	if target <= 0 || len(samples) == target {
+		return samples
+	}
+
+	frames := 0
+	if len(samples) > 0 {
+		frames = len(samples[0])
+	}
+
+	if len(samples) == 1 {
+		out := make([][]float64, target)
+		for ch := range out {
+			out[ch] = samples[0]
+		}
+		return out
+	}
+
+	if target == 1 {
+		mixed := make([]float64, frames)
+		for _, ch := range samples {
+			for i, v := range ch {
+				mixed[i] += v / float64(len(samples))
+			}
+		}
+		return [][]float64{mixed}
+	}
+
+	out := make([][]float64, target)
+	for ch := range out {
+		if ch < len(samples) {
+			out[ch] = samples[ch]
+		} else {
+			out[ch] = samples[len(samples)-1]
+		}
+	}
+	return out
+}
+
+// resample linearly interpolates samples from one sample rate to another
+func resample(samples [][]float64, from, to uint32) [][]float64 {
+	if from == 0 || to == 0 || from == to {
+		return samples
+	}
+
+	ratio := float64(to) / float64(from)
+	out := make([][]float64, len(samples))
+	for ch, data := range samples {
+		resampled := make([]float64, int(float64(len(data))*ratio))
+		for i := range resampled {
+			srcPos := float64(i) / ratio
+			idx := int(srcPos)
+			frac := srcPos - float64(idx)
+			switch {
+			case idx+1 < len(data):
+				resampled[i] = data[idx]*(1-frac) + data[idx+1]*frac
+			case idx < len(data):
+				resampled[i] = data[idx]
+			}
+		}
+		out[ch] = resampled
+	}
+	return out
+}
+
+// decodeSamples decodes channel-interleaved PCM data into one normalized
+// (-1 to 1 for integer PCM, as-is for float PCM) []float64 per channel
+func decodeSamples(format WaveFormat, bigEndian bool, data []byte) [][]float64 {
+	channels := int(format.NumChannels)
+	if channels == 0 {
+		channels = 1
+	}
+	bitsPerSample := int(format.BitsPerSample)
+	if bitsPerSample == 0 {
+		bitsPerSample = 16
+	}
+	isFloat := format.AudioFormat == waveFormatIEEEFloat
+	byteWidth := (bitsPerSample + 7) / 8
+	frameBytes := byteWidth * channels
+	if frameBytes == 0 {
+		return nil
+	}
+	frames := len(data) / frameBytes
+
+	out := make([][]float64, channels)
+	for ch := range out {
+		out[ch] = make([]float64, frames)
+	}
+	for i := 0; i < frames; i++ {
+		for ch := 0; ch < channels; ch++ {
+			offset := i*frameBytes + ch*byteWidth
+			out[ch][i] = readRawSample(data, offset, bitsPerSample, bigEndian, isFloat)
+		}
+	}
+	return out
+}
+
+// encodeSamples is the inverse of decodeSamples
+func encodeSamples(format WaveFormat, bigEndian bool, samples [][]float64) []byte {
+	channels := int(format.NumChannels)
+	if channels == 0 {
+		channels = 1
+	}
+	bitsPerSample := int(format.BitsPerSample)
+	if bitsPerSample == 0 {
+		bitsPerSample = 16
+	}
+	isFloat := format.AudioFormat == waveFormatIEEEFloat
+	byteWidth := (bitsPerSample + 7) / 8
+	frames := 0
+	if len(samples) > 0 {
+		frames = len(samples[0])
+	}
+
+	data := make([]byte, frames*channels*byteWidth)
+	for i := 0; i < frames; i++ {
+		for ch := 0; ch < channels; ch++ {
+			var v float64
+			if ch < len(samples) {
+				v = samples[ch][i]
+			}
+			offset := i*channels*byteWidth + ch*byteWidth
+			writeRawSample(data, offset, v, bitsPerSample, bigEndian, isFloat)
+		}
+	}
+	return data
+}
+
+// readRawSample decodes one sample at offset into a normalized float64:
+// -1 to 1 for integer PCM (8bit unsigned, or 16/24/32bit signed), as-is
+// for 32/64bit IEEE float PCM
+func readRawSample(data []byte, offset, bitsPerSample int, bigEndian, isFloat bool) float64 {
+	byteWidth := (bitsPerSample + 7) / 8
+	if isFloat {
+		switch bitsPerSample {
+		case 32:
+			return float64(math.Float32frombits(endianUint32(data[offset:offset+4], bigEndian)))
+		case 64:
+			return math.Float64frombits(endianUint64(data[offset:offset+8], bigEndian))
+		default:
+			return 0
+		}
+	}
+	if bitsPerSample == 8 {
+		return (float64(data[offset]) - 128) / 128
+	}
+
+	var u uint64
+	for b := 0; b < byteWidth; b++ {
+		idx := b
+		if bigEndian {
+			idx = byteWidth - 1 - b
+		}
+		u |= uint64(data[offset+idx]) << uint(8*b)
+	}
+	bits := uint(byteWidth * 8)
+	iv := int64(u)
+	if u&(1<<(bits-1)) != 0 {
+		iv = int64(u) - (1 << bits)
+	}
+	return float64(iv) / float64(int64(1)<<(bits-1))
+}
+
+// writeRawSample is the inverse of readRawSample
+func writeRawSample(data []byte, offset int, v float64, bitsPerSample int, bigEndian, isFloat bool) {
+	byteWidth := (bitsPerSample + 7) / 8
+	if isFloat {
+		switch bitsPerSample {
+		case 32:
+			putEndianUint32(data[offset:offset+4], math.Float32bits(float32(v)), bigEndian)
+		case 64:
+			putEndianUint64(data[offset:offset+8], math.Float64bits(v), bigEndian)
+		}
+		return
+	}
+	if bitsPerSample == 8 {
+		iv := int64(v*128 + 128)
+		if iv > MAX_8_BIT {
+			iv = MAX_8_BIT
+		} else if iv < MIN_8_BIT {
+			iv = MIN_8_BIT
+		}
+		data[offset] = byte(iv)
+		return
+	}
+
+	bits := uint(byteWidth * 8)
+	max := int64(1)<<(bits-1) - 1
+	min := -(int64(1) << (bits - 1))
+	iv := int64(v * float64(int64(1)<<(bits-1)))
+	if iv > max {
+		iv = max
+	} else if iv < min {
+		iv = min
+	}
+	u := uint64(iv) & (uint64(1)<<bits - 1)
+	for b := 0; b < byteWidth; b++ {
+		idx := b
+		if bigEndian {
+			idx = byteWidth - 1 - b
+		}
+		data[offset+idx] = byte(u >> uint(8*b))
+	}
+}
+
+func endianUint32(b []byte, bigEndian bool) uint32 {
+	if bigEndian {
+		return binary.BigEndian.Uint32(b)
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func endianUint64(b []byte, bigEndian bool) uint64 {
+	if bigEndian {
+		return binary.BigEndian.Uint64(b)
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func putEndianUint32(b []byte, v uint32, bigEndian bool) {
+	if bigEndian {
+		binary.BigEndian.PutUint32(b, v)
+	} else {
+		binary.LittleEndian.PutUint32(b, v)
+	}
+}
+
+func putEndianUint64(b []byte, v uint64, bigEndian bool) {
+	if bigEndian {
+		binary.BigEndian.PutUint64(b, v)
+	} else {
+		binary.LittleEndian.PutUint64(b, v)
+	}
+}
+
+// fromNormalized scales a -1 to 1 float64 into T's native range: as-is for
+// float32/float64, full-scale integer for int16/int32, saturating at the
+// positive boundary since e.g. 1.0*32768 overflows int16's 32767 max. It
+// uses the same 2^(bits-1) scale as readRawSample/writeRawSample, so
+// GetSamples and SetSamples round trip exactly
+func fromNormalized[T Sample](v float64) T {
+	var zero T
+	switch any(zero).(type) {
+	case float32:
+		return any(float32(v)).(T)
+	case float64:
+		return any(v).(T)
+	case int32:
+		raw := v * 2147483648
+		if raw > math.MaxInt32 {
+			raw = math.MaxInt32
+		}
+		return any(int32(raw)).(T)
+	default: // int16
+		raw := v * 32768
+		if raw > math.MaxInt16 {
+			raw = math.MaxInt16
+		}
+		return any(int16(raw)).(T)
+	}
+}
+
+// toNormalized is the inverse of fromNormalized
+func toNormalized[T Sample](v T) float64 {
+	switch x := any(v).(type) {
+	case float32:
+		return float64(x)
+	case float64:
+		return x
+	case int32:
+		return float64(x) / 2147483648
+	case int16:
+		return float64(x) / 32768
+	}
+	return 0
+}
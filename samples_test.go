@@ -0,0 +1,151 @@
+package audiofile
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func Test_GetSamplesDeinterleavesPerChannel(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	af.Header.NumChannels = 2
+	// 4 frames of stereo 16bit: L,R pairs 1,-1 / 2,-2 / 3,-3 / 4,-4
+	af.SetBytes([]byte{
+		1, 0, 255, 255,
+		2, 0, 254, 255,
+		3, 0, 253, 255,
+		4, 0, 252, 255})
+
+	samples := GetSamples[int16](af)
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 channels, got %d", len(samples))
+	}
+	expectedLeft := []int16{1, 2, 3, 4}
+	expectedRight := []int16{-1, -2, -3, -4}
+	if !comparei16(samples[0], expectedLeft) {
+		t.Errorf("expected left channel %v, got %v", expectedLeft, samples[0])
+	}
+	if !comparei16(samples[1], expectedRight) {
+		t.Errorf("expected right channel %v, got %v", expectedRight, samples[1])
+	}
+}
+
+func Test_SetSamplesInterleavesChannels(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	af.Header.NumChannels = 2
+
+	SetSamples(af, [][]int16{{1, 2}, {-1, -2}})
+
+	expected := []byte{1, 0, 255, 255, 2, 0, 254, 255}
+	got := af.GetBytes()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if expected[i] != got[i] {
+			t.Errorf("expected %v, got %v", expected, got)
+			break
+		}
+	}
+}
+
+func Test_GetSamplesSetSamplesRoundTrip(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	af.Header.NumChannels = 1
+
+	SetSamples(af, [][]int16{{0, 1000, -1000, 32767, -32768}})
+	samples := GetSamples[int16](af)
+	expected := []int16{0, 1000, -1000, 32767, -32768}
+	if !comparei16(samples[0], expected) {
+		t.Errorf("expected %v, got %v", expected, samples[0])
+	}
+}
+
+func Test_SetSamplesSaturatesPositiveFullScale(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	af.Header.NumChannels = 1
+
+	SetSamples(af, [][]float64{{1.0}})
+	samples := GetSamples[int16](af)
+	if samples[0][0] != MAX_16_BIT {
+		t.Errorf("expected a normalized 1.0 to saturate to %d, got %d", MAX_16_BIT, samples[0][0])
+	}
+}
+
+func Test_SetSamplesSavesAFormSizeMatchingTheActualFileLength(t *testing.T) {
+	af := &Aifffile{}
+	af.Init()
+	SetSamples(af, [][]int16{{1, 2, 3}})
+
+	var buf bytes.Buffer
+	if err := af.Save(&buf); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	wantFormSize := uint32(buf.Len() - 8)
+	if af.Header.FormSize != wantFormSize {
+		t.Errorf("expected FormSize %d (file is %d bytes), got %d", wantFormSize, buf.Len(), af.Header.FormSize)
+	}
+}
+
+func Test_ConvertToMonoToStereo(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	af.Header.NumChannels = 1
+	SetSamples(af, [][]int16{{1, 2, 3}})
+
+	if err := ConvertTo(af, WaveFormat{NumChannels: 2, SampleRate: 44100, BitsPerSample: 16}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if af.Header.NumChannels != 2 {
+		t.Errorf("expected 2 channels, got %d", af.Header.NumChannels)
+	}
+	samples := GetSamples[int16](af)
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 channels, got %d", len(samples))
+	}
+	if !comparei16(samples[0], samples[1]) {
+		t.Errorf("expected both channels to carry the mono source, got %v and %v", samples[0], samples[1])
+	}
+}
+
+func Test_ConvertToBitDepth(t *testing.T) {
+	af := &Wavefile{}
+	af.Init()
+	af.Header.NumChannels = 1
+	SetSamples(af, [][]int16{{0, 32767, -32768}})
+
+	if err := ConvertTo(af, WaveFormat{NumChannels: 1, SampleRate: 44100, BitsPerSample: 8}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if af.Header.BitsPerSample != 8 {
+		t.Errorf("expected 8bit, got %d", af.Header.BitsPerSample)
+	}
+	if len(af.GetBytes()) != 3 {
+		t.Errorf("expected 3 bytes for 3 8bit samples, got %d", len(af.GetBytes()))
+	}
+}
+
+func Test_ConvertToReturnsBadFileForUnknownContainers(t *testing.T) {
+	af := &unknownAudioFile{}
+	err := ConvertTo(af, WaveFormat{NumChannels: 1, BitsPerSample: 16})
+	if err != BadFile {
+		t.Errorf("expected BadFile, got %v", err)
+	}
+}
+
+type unknownAudioFile struct {
+	data []byte
+}
+
+func (u *unknownAudioFile) Init()                  {}
+func (u *unknownAudioFile) GetBytes() []byte       { return u.data }
+func (u *unknownAudioFile) SetBytes(b []byte)      { u.data = b }
+func (u *unknownAudioFile) Load(r io.Reader) error { return nil }
+func (u *unknownAudioFile) Save(w io.Writer) error { return nil }
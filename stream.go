@@ -0,0 +1,253 @@
+package audiofile
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Decoder reads WAV PCM data incrementally from an io.Reader, without
+// buffering the whole file into memory the way Wavefile.Load does --
+// useful for processing gigabyte-scale files
+type Decoder struct {
+	r         io.Reader
+	format    WaveFormat
+	remaining uint32
+}
+
+// NewDecoder reads and parses the RIFF/WAVE header and fmt chunk from r,
+// stopping as soon as it reaches the data chunk so ReadSamples can stream
+// the PCM payload afterward
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	var chunkID, form [4]byte
+	var chunkSize uint32
+	if err := binary.Read(r, binary.BigEndian, &chunkID); err != nil {
+		return nil, BadFile
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunkSize); err != nil {
+		return nil, BadFile
+	}
+	if err := binary.Read(r, binary.BigEndian, &form); err != nil {
+		return nil, BadFile
+	}
+	if chunkID != [4]byte{'R', 'I', 'F', 'F'} || form != [4]byte{'W', 'A', 'V', 'E'} {
+		return nil, BadFile
+	}
+
+	d := &Decoder{r: r}
+	for {
+		var id [4]byte
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &id); err != nil {
+			return nil, BadFile
+		}
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, BadFile
+		}
+
+		if id == ([4]byte{'f', 'm', 't', ' '}) {
+			payload := make([]byte, size)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return nil, BadFile
+			}
+			if size%2 != 0 {
+				var pad [1]byte
+				io.ReadFull(r, pad[:])
+			}
+			wf := &Wavefile{}
+			if err := wf.parseFmt(id, size, payload); err != nil {
+				return nil, err
+			}
+			d.format = wf.Format
+			continue
+		}
+
+		if id == ([4]byte{'d', 'a', 't', 'a'}) {
+			d.remaining = size
+			break
+		}
+
+		if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+			return nil, BadFile
+		}
+		if size%2 != 0 {
+			var pad [1]byte
+			io.ReadFull(r, pad[:])
+		}
+	}
+
+	if d.format.BitsPerSample != 16 {
+		return nil, BadFile
+	}
+	return d, nil
+}
+
+// Format returns the WaveFormat parsed from the stream's fmt chunk
+func (d *Decoder) Format() WaveFormat {
+	return d.format
+}
+
+// ReadSamples fills buf with up to len(buf) 16bit signed samples read from
+// the data chunk, returning the number read. It returns io.EOF once the
+// data chunk is exhausted, same as io.Reader. Only 16bit PCM is supported;
+// NewDecoder rejects any other bit depth, since reinterpreting them as
+// 16bit samples would silently misread the stream (and for odd byte
+// widths, desync it)
+func (d *Decoder) ReadSamples(buf []int16) (int, error) {
+	if d.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	maxSamples := int(d.remaining / 2)
+	if len(buf) > maxSamples {
+		buf = buf[:maxSamples]
+	}
+
+	raw := make([]byte, len(buf)*2)
+	n, err := io.ReadFull(d.r, raw)
+	samples := n / 2
+	for i := 0; i < samples; i++ {
+		buf[i] = BytesToSigned16(raw[i*2], raw[i*2+1])
+	}
+	d.remaining -= uint32(n)
+
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return samples, err
+}
+
+// Encoder writes WAV PCM data incrementally to an io.Writer. Close patches
+// the header's ChunkSize/Subchunk2Size fields in place when w is also an
+// io.WriteSeeker; when it isn't, the final size can't be known up front or
+// rewritten after the fact, so NewEncoder emits an RF64/BWF64-style header
+// with the sizes marked unknown instead
+type Encoder struct {
+	w       io.Writer
+	written uint32
+	rf64    bool
+}
+
+// NewEncoder writes the WAVE header (RIFF or, for a plain io.Writer that
+// can't be seeked back into, RF64/BWF64) and a canonical 16 byte fmt chunk
+// for format, then returns an Encoder ready to stream PCM via Write
+func NewEncoder(w io.Writer, format WaveFormat) (*Encoder, error) {
+	_, seekable := w.(io.WriteSeeker)
+	e := &Encoder{w: w, rf64: !seekable}
+
+	if e.rf64 {
+		if err := binary.Write(w, binary.BigEndian, [4]byte{'R', 'F', '6', '4'}); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(0xFFFFFFFF)); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := binary.Write(w, binary.BigEndian, [4]byte{'R', 'I', 'F', 'F'}); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(36)); err != nil {
+			return nil, err
+		}
+	}
+	if err := binary.Write(w, binary.BigEndian, [4]byte{'W', 'A', 'V', 'E'}); err != nil {
+		return nil, err
+	}
+
+	if e.rf64 {
+		if err := binary.Write(w, binary.BigEndian, [4]byte{'d', 's', '6', '4'}); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(28)); err != nil {
+			return nil, err
+		}
+		unknown := uint64(0xFFFFFFFFFFFFFFFF)
+		if err := binary.Write(w, binary.LittleEndian, unknown); err != nil { // riffSize
+			return nil, err
+		}
+		if err := binary.Write(w, binary.LittleEndian, unknown); err != nil { // dataSize
+			return nil, err
+		}
+		if err := binary.Write(w, binary.LittleEndian, unknown); err != nil { // sampleCount
+			return nil, err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(0)); err != nil { // tableLength
+			return nil, err
+		}
+	}
+
+	if err := binary.Write(w, binary.BigEndian, [4]byte{'f', 'm', 't', ' '}); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(16)); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, format.AudioFormat); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, format.NumChannels); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, format.SampleRate); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, format.ByteRate); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, format.BlockAlign); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, format.BitsPerSample); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(w, binary.BigEndian, [4]byte{'d', 'a', 't', 'a'}); err != nil {
+		return nil, err
+	}
+	sizePlaceholder := uint32(0)
+	if e.rf64 {
+		sizePlaceholder = 0xFFFFFFFF
+	}
+	if err := binary.Write(w, binary.LittleEndian, sizePlaceholder); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Write streams raw PCM bytes to the underlying writer, accumulating the
+// byte count Close needs to patch the header with
+func (e *Encoder) Write(p []byte) (int, error) {
+	n, err := e.w.Write(p)
+	e.written += uint32(n)
+	return n, err
+}
+
+// Close patches ChunkSize and Subchunk2Size in place if the Encoder was
+// given an io.WriteSeeker. RF64/BWF64 streams already declare their sizes
+// unknown and can't be patched after the fact, so Close is a no-op for them
+func (e *Encoder) Close() error {
+	if e.rf64 {
+		return nil
+	}
+	ws, ok := e.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+
+	if _, err := ws.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(ws, binary.LittleEndian, uint32(36)+e.written); err != nil {
+		return err
+	}
+	if _, err := ws.Seek(40, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(ws, binary.LittleEndian, e.written); err != nil {
+		return err
+	}
+	if _, err := ws.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package audiofile
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func Test_ReadFromReturnsByteCount(t *testing.T) {
+	af := &Wavefile{}
+	n, err := af.ReadFrom(bytes.NewBuffer(validWaveBuf))
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if n != int64(len(validWaveBuf)) {
+		t.Errorf("expected to read %v bytes, got %v", len(validWaveBuf), n)
+	}
+}
+
+func Test_WriteToReturnsByteCount(t *testing.T) {
+	af := &Wavefile{}
+	if err := af.Load(bytes.NewBuffer(validWaveBuf)); err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(validWaveBuf)))
+	n, err := af.WriteTo(out)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if n != int64(len(validWaveBuf)) {
+		t.Errorf("expected to write %v bytes, got %v", len(validWaveBuf), n)
+	}
+}
+
+func Test_NewDecoderParsesFormat(t *testing.T) {
+	data := []byte{1, 0, 2, 0, 3, 0}
+	newBuf := bytes.Join([][]byte{validWaveBuf, data}, nil)
+	newBuf[40] = byte(len(data))
+
+	dec, err := NewDecoder(bytes.NewBuffer(newBuf))
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+	f := dec.Format()
+	if f.SampleRate != 44100 || f.NumChannels != 1 || f.BitsPerSample != 16 {
+		t.Errorf("expected parsed format to match the fmt chunk, got %+v", f)
+	}
+}
+
+func Test_NewDecoderRejectsNon16BitFormats(t *testing.T) {
+	buf24 := make([]byte, len(validWaveBuf))
+	copy(buf24, validWaveBuf)
+	buf24[34] = 24 // BitsPerSample -> 24, which ReadSamples can't decode
+
+	if _, err := NewDecoder(bytes.NewBuffer(buf24)); err != BadFile {
+		t.Errorf("expected BadFile for a 24bit format, got %v", err)
+	}
+}
+
+func Test_DecoderReadSamplesStreamsData(t *testing.T) {
+	data := []byte{1, 0, 2, 0, 3, 0}
+	newBuf := bytes.Join([][]byte{validWaveBuf, data}, nil)
+	newBuf[40] = byte(len(data))
+
+	dec, err := NewDecoder(bytes.NewBuffer(newBuf))
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+
+	buf := make([]int16, 2)
+	n, err := dec.ReadSamples(buf)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if n != 2 || buf[0] != 1 || buf[1] != 2 {
+		t.Errorf("expected first two samples [1 2], got %v (n=%v)", buf[:n], n)
+	}
+
+	n, err = dec.ReadSamples(buf)
+	if n != 1 || buf[0] != 3 {
+		t.Errorf("expected last sample [3], got %v (n=%v)", buf[:n], n)
+	}
+	if err != nil && err != io.EOF {
+		t.Errorf("expected nil or io.EOF, got %v", err)
+	}
+
+	n, err = dec.ReadSamples(buf)
+	if n != 0 || err != io.EOF {
+		t.Errorf("expected io.EOF once the data chunk is exhausted, got n=%v err=%v", n, err)
+	}
+}
+
+func Test_NewEncoderUsesRF64ForNonSeekableWriter(t *testing.T) {
+	var buf bytes.Buffer
+	format := WaveFormat{AudioFormat: 1, NumChannels: 1, SampleRate: 44100, ByteRate: 88200, BlockAlign: 2, BitsPerSample: 16}
+	enc, err := NewEncoder(&buf, format)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+	if _, err := enc.Write([]byte{1, 0}); err != nil {
+		t.Errorf("expected no error writing pcm, got %v", err)
+		return
+	}
+	if err := enc.Close(); err != nil {
+		t.Errorf("expected no error closing, got %v", err)
+		return
+	}
+	got := buf.Bytes()
+	if bytes.Compare(got[:4], []byte{'R', 'F', '6', '4'}) != 0 {
+		t.Errorf("expected an RF64 magic number, got %v", got[:4])
+	}
+	if bytes.Compare(got[12:16], []byte{'d', 's', '6', '4'}) != 0 {
+		t.Errorf("expected a ds64 chunk, got %v", got[12:16])
+	}
+}
+
+// seekableBuffer is a minimal in-memory io.WriteSeeker, standing in for a
+// real *os.File so Encoder.Close can be exercised without touching disk
+type seekableBuffer struct {
+	buf []byte
+	pos int64
+}
+
+func (s *seekableBuffer) Write(p []byte) (int, error) {
+	end := s.pos + int64(len(p))
+	if end > int64(len(s.buf)) {
+		grown := make([]byte, end)
+		copy(grown, s.buf)
+		s.buf = grown
+	}
+	copy(s.buf[s.pos:end], p)
+	s.pos = end
+	return len(p), nil
+}
+
+func (s *seekableBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		s.pos = offset
+	case io.SeekCurrent:
+		s.pos += offset
+	case io.SeekEnd:
+		s.pos = int64(len(s.buf)) + offset
+	}
+	return s.pos, nil
+}
+
+func Test_EncoderPatchesSizesForSeekableWriter(t *testing.T) {
+	sb := &seekableBuffer{}
+	format := WaveFormat{AudioFormat: 1, NumChannels: 1, SampleRate: 44100, ByteRate: 88200, BlockAlign: 2, BitsPerSample: 16}
+	enc, err := NewEncoder(sb, format)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+		return
+	}
+	pcm := []byte{1, 0, 2, 0, 3, 0}
+	if _, err := enc.Write(pcm); err != nil {
+		t.Errorf("expected no error writing pcm, got %v", err)
+		return
+	}
+	if err := enc.Close(); err != nil {
+		t.Errorf("expected no error closing, got %v", err)
+		return
+	}
+
+	wf := &Wavefile{}
+	if err := wf.Load(bytes.NewBuffer(sb.buf)); err != nil {
+		t.Errorf("expected encoder output to be a valid wav, got %v", err)
+		return
+	}
+	if bytes.Compare(wf.GetBytes(), pcm) != 0 {
+		t.Errorf("expected %v, got %v", pcm, wf.GetBytes())
+	}
+}